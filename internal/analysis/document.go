@@ -0,0 +1,41 @@
+// Package analysis builds per-document symbol information on top of the
+// internal/nix AST: scope chains for let bindings, function parameters,
+// rec attrsets, inherit clauses, and with expressions. It is the shared
+// foundation used by completion, hover, and go-to-definition.
+package analysis
+
+import (
+	"go.lsp.dev/protocol"
+
+	"github.com/legonois/nixos-lsp/internal/nix"
+)
+
+// Document is the analyzed form of a single Nix source file: its parsed
+// AST plus the scope tree built over it.
+type Document struct {
+	URI     protocol.URI
+	Text    string
+	File    *nix.File
+	Root    *Scope
+	scopes  []*Scope // every scope in the document, in creation order
+	version int32
+}
+
+// Parse analyzes text and returns the resulting Document for uri.
+func Parse(uri protocol.URI, text string, version int32) *Document {
+	f := nix.Parse(text)
+	d := &Document{URI: uri, Text: text, File: f, version: version}
+	d.Root = newScope(nil, nix.Span{})
+	d.scopes = append(d.scopes, d.Root)
+	if f.Root != nil {
+		d.buildScope(f.Root, d.Root)
+	}
+	return d
+}
+
+// Version returns the document-sync version this Document was parsed at.
+func (d *Document) Version() int32 { return d.version }
+
+// SyntaxErrors reports the parse errors found while analyzing the
+// document. Turning these into LSP diagnostics is left to callers.
+func (d *Document) SyntaxErrors() []nix.SyntaxError { return d.File.Errors }
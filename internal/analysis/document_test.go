@@ -0,0 +1,72 @@
+package analysis
+
+import (
+	"testing"
+
+	"go.lsp.dev/protocol"
+
+	"github.com/legonois/nixos-lsp/internal/nix"
+)
+
+func TestLetBindingVisibleInBody(t *testing.T) {
+	src := `let x = 1; in x + 1`
+	d := Parse("file:///t.nix", src, 1)
+
+	pos := nix.Position{Offset: len(src) - 1}
+	sym, _, ok := d.ScopeAt(pos).Lookup("x")
+	if !ok {
+		t.Fatalf("expected x to resolve in let body")
+	}
+	if sym.Kind != SymbolLet {
+		t.Fatalf("got kind %v, want SymbolLet", sym.Kind)
+	}
+}
+
+func TestRecAttrSeesSiblings(t *testing.T) {
+	src := `rec { a = 1; b = a + 1; }`
+	d := Parse(protocol.URI("file:///t.nix"), src, 1)
+
+	bPos := nix.Position{Offset: len(`rec { a = 1; b = `)}
+	sym, _, ok := d.ScopeAt(bPos).Lookup("a")
+	if !ok || sym.Kind != SymbolRecAttr {
+		t.Fatalf("expected rec attr a visible to sibling b, got ok=%v sym=%#v", ok, sym)
+	}
+}
+
+func TestNonRecAttrDoesNotSeeSiblings(t *testing.T) {
+	src := `{ a = 1; b = a + 1; }`
+	d := Parse(protocol.URI("file:///t.nix"), src, 1)
+
+	bPos := nix.Position{Offset: len(`{ a = 1; b = `)}
+	if _, _, ok := d.ScopeAt(bPos).Lookup("a"); ok {
+		t.Fatalf("plain attrset bindings must not see each other")
+	}
+}
+
+func TestFunctionParamsInScope(t *testing.T) {
+	src := `{ a, b ? 2, ... }@args: a + b`
+	d := Parse(protocol.URI("file:///t.nix"), src, 1)
+
+	pos := nix.Position{Offset: len(src) - 1}
+	scope := d.ScopeAt(pos)
+	for _, name := range []string{"a", "b", "args"} {
+		if _, _, ok := scope.Lookup(name); !ok {
+			t.Errorf("expected %q to be in scope", name)
+		}
+	}
+}
+
+func TestWithExposesFallbackTarget(t *testing.T) {
+	src := `with pkgs; hello`
+	d := Parse(protocol.URI("file:///t.nix"), src, 1)
+
+	pos := nix.Position{Offset: len(src) - 1}
+	withs := d.ScopeAt(pos).Withs()
+	if len(withs) != 1 {
+		t.Fatalf("got %d with-targets, want 1", len(withs))
+	}
+	ident, ok := withs[0].(*nix.Ident)
+	if !ok || ident.Name != "pkgs" {
+		t.Fatalf("with target = %#v, want Ident(pkgs)", withs[0])
+	}
+}
@@ -0,0 +1,534 @@
+package analysis
+
+import (
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"go.lsp.dev/protocol"
+
+	"github.com/legonois/nixos-lsp/internal/nix"
+)
+
+// maxImportHops bounds how many `import`/attribute hops resolveAttrSet will
+// chase before giving up, so a cyclic or self-referential import can't spin
+// the resolver forever.
+const maxImportHops = 32
+
+// SpanToRange converts sp, whose Positions carry byte offsets into text
+// (matching nix.Lexer's own tracking), into an LSP Range, whose Character
+// fields are UTF-16 code unit counts per the LSP spec.
+func SpanToRange(text string, sp nix.Span) protocol.Range {
+	return protocol.Range{
+		Start: utf16Position(text, sp.Start),
+		End:   utf16Position(text, sp.End),
+	}
+}
+
+// utf16Position converts a nix.Position's byte Offset into an LSP Position
+// by counting UTF-16 code units from the start of its line.
+func utf16Position(text string, pos nix.Position) protocol.Position {
+	lineStart := pos.Offset - pos.Column
+	return protocol.Position{
+		Line:      uint32(pos.Line),
+		Character: uint32(utf16Units(text[lineStart:pos.Offset])),
+	}
+}
+
+// UTF16Units counts how many UTF-16 code units s encodes to, for callers
+// outside this package (e.g. semtok, format) that need to translate a byte
+// span into the LSP wire format's UTF-16 units without going through a full
+// Range.
+func UTF16Units(s string) int {
+	return utf16Units(s)
+}
+
+// utf16Units counts how many UTF-16 code units s encodes to.
+func utf16Units(s string) int {
+	units := 0
+	for _, r := range s {
+		units += utf16.RuneLen(r)
+	}
+	return units
+}
+
+// PosFromLSP converts an LSP Position (0-based line, UTF-16 character) into
+// the nix.Position (with a resolved byte Offset and byte Column, matching
+// nix.Lexer's own tracking) that Document methods expect.
+func PosFromLSP(p protocol.Position, text string) nix.Position {
+	return offsetPosition(text, int(p.Line), int(p.Character))
+}
+
+// offsetPosition converts a 0-based line and UTF-16 character count into a
+// nix.Position with a byte Offset into text, by walking lineStart's runes
+// and accumulating UTF-16 code units until utf16Col is reached.
+func offsetPosition(text string, line, utf16Col int) nix.Position {
+	offset, curLine, lineStart := 0, 0, 0
+	for offset < len(text) && curLine < line {
+		if text[offset] == '\n' {
+			curLine++
+			lineStart = offset + 1
+		}
+		offset++
+	}
+
+	pos := lineStart
+	units := 0
+	for units < utf16Col && pos < len(text) && text[pos] != '\n' {
+		r, size := utf8.DecodeRuneInString(text[pos:])
+		pos += size
+		units += utf16.RuneLen(r)
+	}
+	return nix.Position{Line: line, Column: pos - lineStart, Offset: pos}
+}
+
+// Definition resolves the identifier, attribute-path segment, or path
+// literal at pos to its defining location(s).
+//
+// fetchurl (and similarly builtins.fetchTarball, pkgs.fetchgit, ...) is
+// deliberately not resolved here: it names a remote store artifact, not a
+// file anywhere in the workspace, so there's no source location to jump
+// to — unlike `import ./other.nix`, whose argument is itself the location.
+func (sn *Snapshot) Definition(doc *Document, pos nix.Position) []protocol.Location {
+	path := nix.FindPath(doc.File.Root, pos)
+	if len(path) == 0 {
+		return nil
+	}
+	switch n := path[len(path)-1].(type) {
+	case *nix.Ident:
+		return sn.definitionForIdent(doc, n)
+	case *nix.Select:
+		return sn.definitionForSelect(doc, n, pos)
+	case *nix.PathLit:
+		return sn.definitionForPath(doc, n)
+	}
+	return nil
+}
+
+func (sn *Snapshot) definitionForIdent(doc *Document, id *nix.Ident) []protocol.Location {
+	r, ok := sn.ResolveIdent(doc, id)
+	if !ok {
+		return nil
+	}
+	return []protocol.Location{{URI: r.Doc.URI, Range: SpanToRange(r.Doc.Text, r.Span)}}
+}
+
+// definitionForPath resolves a path literal directly to the file it names —
+// e.g. placing the cursor on `./other.nix` in `import ./other.nix`, or on a
+// bare path expression anywhere else it can appear — parsing and caching it
+// in the Session on demand the same way resolveImport does.
+func (sn *Snapshot) definitionForPath(doc *Document, p *nix.PathLit) []protocol.Location {
+	target := ResolveRelativePath(doc.URI, p.Value)
+	if _, ok := sn.getOrLoad(target); !ok {
+		return nil
+	}
+	return []protocol.Location{{URI: target}}
+}
+
+func (sn *Snapshot) definitionForSelect(doc *Document, sel *nix.Select, pos nix.Position) []protocol.Location {
+	r, ok := sn.ResolveSelect(doc, sel, pos)
+	if !ok {
+		return nil
+	}
+	return []protocol.Location{{URI: r.Doc.URI, Range: SpanToRange(r.Doc.Text, r.Span)}}
+}
+
+// Resolved is what ResolveIdent and ResolveSelect report about a binding:
+// the document and name span it's defined at (what Definition needs), plus —
+// when statically known — the raw expression it's bound to, which Definition
+// discards but Hover needs in order to classify and render the value.
+type Resolved struct {
+	Doc   *Document
+	Span  nix.Span
+	Value nix.Expr
+}
+
+// ResolveIdent finds where id is bound: a let/rec/param binding, an
+// inherited name, or a member of some `with expr;` in scope.
+func (sn *Snapshot) ResolveIdent(doc *Document, id *nix.Ident) (Resolved, bool) {
+	return sn.resolveSymbolAt(doc, doc.ScopeAt(id.Span().Start), id.Name)
+}
+
+// resolveSymbolAt is ResolveIdent's scope-and-name-based core, factored out
+// so References can resolve a name directly from its own declaration site —
+// where scope.Lookup already holds the Symbol — without having to build a
+// synthetic *nix.Ident to feed back through ResolveIdent.
+func (sn *Snapshot) resolveSymbolAt(doc *Document, scope *Scope, name string) (Resolved, bool) {
+	if sym, _, ok := scope.Lookup(name); ok {
+		if sym.Kind == SymbolInherit && sym.From != nil {
+			if set, setDoc, ok2 := sn.resolveAttrSet(doc, sym.From); ok2 {
+				if r, ok3 := sn.findAttrBinding(setDoc, set, name); ok3 {
+					return r, true
+				}
+			}
+			return Resolved{Doc: doc, Span: sym.NameSpan}, true
+		}
+		return Resolved{Doc: doc, Span: sym.NameSpan, Value: sym.Value}, true
+	}
+	for _, w := range scope.Withs() {
+		if set, setDoc, ok := sn.resolveAttrSet(doc, w); ok {
+			if r, ok2 := sn.findAttrBinding(setDoc, set, name); ok2 {
+				return r, true
+			}
+		}
+	}
+	return Resolved{}, false
+}
+
+// ResolveSelect finds where the attribute-path segment at pos inside sel is
+// bound, chasing every segment before it through the static attrset
+// resolver.
+func (sn *Snapshot) ResolveSelect(doc *Document, sel *nix.Select, pos nix.Position) (Resolved, bool) {
+	idx := -1
+	for i, seg := range sel.Path {
+		if seg.Span.Contains(pos) {
+			idx = i
+			break
+		}
+	}
+	if idx < 0 {
+		return Resolved{}, false
+	}
+	set, setDoc, ok := sn.resolveAttrSet(doc, sel.Expr)
+	if !ok {
+		return Resolved{}, false
+	}
+	for i := 0; i < idx; i++ {
+		if sel.Path[i].Name == "" {
+			return Resolved{}, false
+		}
+		val, valDoc, ok2 := sn.lookupAttrValue(setDoc, set, sel.Path[i].Name)
+		if !ok2 {
+			return Resolved{}, false
+		}
+		set, setDoc, ok2 = sn.resolveAttrSet(valDoc, val)
+		if !ok2 {
+			return Resolved{}, false
+		}
+	}
+	if sel.Path[idx].Name == "" {
+		return Resolved{}, false
+	}
+	return sn.findAttrBinding(setDoc, set, sel.Path[idx].Name)
+}
+
+// resolveAttrSet statically evaluates expr, as far as it can without
+// actually running the Nix evaluator, down to an attrset literal: through
+// identifier/with lookups, `inherit`, `import ./other.nix`, and chained
+// attribute selects. It gives up (ok=false) the moment it hits anything
+// genuinely dynamic, such as a function call.
+func (sn *Snapshot) resolveAttrSet(doc *Document, expr nix.Expr) (*nix.AttrSet, *Document, bool) {
+	return sn.resolveAttrSetDepth(doc, expr, 0)
+}
+
+func (sn *Snapshot) resolveAttrSetDepth(doc *Document, expr nix.Expr, depth int) (*nix.AttrSet, *Document, bool) {
+	if depth > maxImportHops || expr == nil {
+		return nil, nil, false
+	}
+	switch e := expr.(type) {
+	case *nix.AttrSet:
+		return e, doc, true
+
+	case *nix.Ident:
+		scope := doc.ScopeAt(e.Span().Start)
+		sym, _, ok := scope.Lookup(e.Name)
+		if ok {
+			if sym.Value != nil {
+				return sn.resolveAttrSetDepth(doc, sym.Value, depth+1)
+			}
+			if sym.Kind == SymbolInherit && sym.From != nil {
+				if set, setDoc, ok2 := sn.resolveAttrSetDepth(doc, sym.From, depth+1); ok2 {
+					if val, valDoc, ok3 := sn.lookupAttrValue(setDoc, set, e.Name); ok3 {
+						return sn.resolveAttrSetDepth(valDoc, val, depth+1)
+					}
+				}
+			}
+			return nil, nil, false
+		}
+		for _, w := range scope.Withs() {
+			if set, setDoc, ok2 := sn.resolveAttrSetDepth(doc, w, depth+1); ok2 {
+				if val, valDoc, ok3 := sn.lookupAttrValue(setDoc, set, e.Name); ok3 {
+					return sn.resolveAttrSetDepth(valDoc, val, depth+1)
+				}
+			}
+		}
+		return nil, nil, false
+
+	case *nix.Select:
+		set, setDoc, ok := sn.resolveAttrSetDepth(doc, e.Expr, depth+1)
+		if !ok {
+			return nil, nil, false
+		}
+		for _, seg := range e.Path {
+			if seg.Name == "" {
+				return nil, nil, false
+			}
+			val, valDoc, ok2 := sn.lookupAttrValue(setDoc, set, seg.Name)
+			if !ok2 {
+				return nil, nil, false
+			}
+			set, setDoc, ok2 = sn.resolveAttrSetDepth(valDoc, val, depth+1)
+			if !ok2 {
+				return nil, nil, false
+			}
+		}
+		return set, setDoc, true
+
+	case *nix.Apply:
+		if fn, ok := e.Fn.(*nix.Ident); ok && fn.Name == "import" {
+			return sn.resolveImport(doc, e.Arg, depth+1)
+		}
+		return nil, nil, false
+	}
+	return nil, nil, false
+}
+
+// ResolveAttrSet is the exported form of the static attrset resolver,
+// for callers outside this package (completion's `with expr;` expansion)
+// that need to chase an arbitrary expression down to an attrset literal.
+func (sn *Snapshot) ResolveAttrSet(doc *Document, expr nix.Expr) (*nix.AttrSet, *Document, bool) {
+	return sn.resolveAttrSetDepth(doc, expr, 0)
+}
+
+// LookupAttrPath statically resolves a dotted attribute path (e.g.
+// ["pkgs", "lib"] for `pkgs.lib`) starting from the identifier scope at
+// pos, for completion's attribute-path context. It gives up (ok=false) the
+// moment any segment can't be resolved without evaluation.
+func (sn *Snapshot) LookupAttrPath(doc *Document, pos nix.Position, path []string) (*nix.AttrSet, *Document, bool) {
+	if len(path) == 0 {
+		return nil, nil, false
+	}
+	scope := doc.ScopeAt(pos)
+	sym, _, ok := scope.Lookup(path[0])
+	if !ok || sym.Value == nil {
+		return nil, nil, false
+	}
+	set, setDoc, ok := sn.resolveAttrSetDepth(doc, sym.Value, 0)
+	if !ok {
+		return nil, nil, false
+	}
+	for _, seg := range path[1:] {
+		val, valDoc, ok2 := sn.lookupAttrValue(setDoc, set, seg)
+		if !ok2 {
+			return nil, nil, false
+		}
+		set, setDoc, ok2 = sn.resolveAttrSetDepth(valDoc, val, 0)
+		if !ok2 {
+			return nil, nil, false
+		}
+	}
+	return set, setDoc, true
+}
+
+func (sn *Snapshot) resolveImport(doc *Document, arg nix.Expr, depth int) (*nix.AttrSet, *Document, bool) {
+	path, ok := arg.(*nix.PathLit)
+	if !ok {
+		return nil, nil, false
+	}
+	target := ResolveRelativePath(doc.URI, path.Value)
+	targetDoc, ok := sn.getOrLoad(target)
+	if !ok {
+		return nil, nil, false
+	}
+	return sn.resolveAttrSetDepth(targetDoc, targetDoc.File.Root, depth)
+}
+
+// lookupAttrValue finds name's bound expression directly inside set
+// (without recursing into its value), for chaining further selects or
+// imports onto it. Every AttrBinding whose head is name contributes: one
+// with no further path segments wins outright, and multiple multi-segment
+// siblings (e.g. `services.nginx.enable = ...;` alongside
+// `services.openssh.enable = ...;` in the same set) are merged into one
+// synthetic attrset so chasing further segments sees all of them, not just
+// whichever came first.
+func (sn *Snapshot) lookupAttrValue(doc *Document, set *nix.AttrSet, name string) (nix.Expr, *Document, bool) {
+	var suffixes []nix.Binding
+	for _, b := range set.Bindings {
+		switch bind := b.(type) {
+		case *nix.AttrBinding:
+			if len(bind.Path) == 0 || bind.Path[0].Name != name {
+				continue
+			}
+			if len(bind.Path) == 1 {
+				return bind.Value, doc, true
+			}
+			suffixes = append(suffixes, &nix.AttrBinding{Path: bind.Path[1:], Value: bind.Value})
+		case *nix.InheritBinding:
+			for _, n := range bind.Names {
+				if n.Name != name {
+					continue
+				}
+				if bind.From == nil {
+					return nil, nil, false
+				}
+				if fromSet, fromDoc, ok := sn.resolveAttrSetDepth(doc, bind.From, 0); ok {
+					return sn.lookupAttrValue(fromDoc, fromSet, name)
+				}
+				return nil, nil, false
+			}
+		}
+	}
+	if len(suffixes) > 0 {
+		return &nix.AttrSet{Bindings: suffixes}, doc, true
+	}
+	return nil, nil, false
+}
+
+// findAttrLocation finds where name is bound inside set, for
+// go-to-definition — unlike lookupAttrValue it always returns a location
+// even for bindings (like `inherit name;` with no `from`) whose value we
+// can't statically chase any further.
+func (sn *Snapshot) findAttrLocation(doc *Document, set *nix.AttrSet, name string) (protocol.Location, bool) {
+	r, ok := sn.findAttrBinding(doc, set, name)
+	if !ok {
+		return protocol.Location{}, false
+	}
+	return protocol.Location{URI: r.Doc.URI, Range: SpanToRange(r.Doc.Text, r.Span)}, true
+}
+
+// findAttrBinding is findAttrLocation's Resolved-returning counterpart,
+// keeping the bound expression (when statically known) alongside the
+// definition span for Hover's benefit.
+func (sn *Snapshot) findAttrBinding(doc *Document, set *nix.AttrSet, name string) (Resolved, bool) {
+	var span nix.Span
+	var suffixes []nix.Binding
+	for _, b := range set.Bindings {
+		switch bind := b.(type) {
+		case *nix.AttrBinding:
+			if len(bind.Path) == 0 || bind.Path[0].Name != name {
+				continue
+			}
+			if len(suffixes) == 0 {
+				span = bind.Path[0].Span
+			}
+			if len(bind.Path) == 1 {
+				return Resolved{Doc: doc, Span: bind.Path[0].Span, Value: bind.Value}, true
+			}
+			suffixes = append(suffixes, &nix.AttrBinding{Path: bind.Path[1:], Value: bind.Value})
+		case *nix.InheritBinding:
+			for _, n := range bind.Names {
+				if n.Name != name {
+					continue
+				}
+				if bind.From != nil {
+					if fromSet, fromDoc, ok := sn.resolveAttrSetDepth(doc, bind.From, 0); ok {
+						if r, ok2 := sn.findAttrBinding(fromDoc, fromSet, name); ok2 {
+							return r, true
+						}
+					}
+				}
+				return Resolved{Doc: doc, Span: n.Span}, true
+			}
+		}
+	}
+	if len(suffixes) > 0 {
+		return Resolved{Doc: doc, Span: span, Value: &nix.AttrSet{Bindings: suffixes}}, true
+	}
+	return Resolved{}, false
+}
+
+// References finds every use of the name at pos: an identifier use site, an
+// attribute-path segment (the inverse of ResolveSelect), or the binding's own
+// declaration — a let/rec name, an `inherit`ed name, or a function parameter —
+// and reports every occurrence anywhere in the workspace's currently loaded
+// documents that resolves to that same definition.
+func (sn *Snapshot) References(doc *Document, pos nix.Position) []protocol.Location {
+	path := nix.FindPath(doc.File.Root, pos)
+	if len(path) == 0 {
+		return nil
+	}
+
+	var target Resolved
+	var ok bool
+	switch n := path[len(path)-1].(type) {
+	case *nix.Ident:
+		target, ok = sn.ResolveIdent(doc, n)
+	case *nix.Select:
+		target, ok = sn.ResolveSelect(doc, n, pos)
+	case *nix.AttrBinding:
+		if name, found := attrBindingNameAt(n, pos); found {
+			target, ok = sn.resolveSymbolAt(doc, doc.ScopeAt(pos), name)
+		}
+	case *nix.InheritBinding:
+		if name, found := inheritNameAt(n, pos); found {
+			target, ok = sn.resolveSymbolAt(doc, doc.ScopeAt(pos), name)
+		}
+	case *nix.IdentParam:
+		target, ok = sn.resolveSymbolAt(doc, doc.ScopeAt(pos), n.Name)
+	case *nix.PatternParam:
+		if name, found := patternParamNameAt(n, pos); found {
+			target, ok = sn.resolveSymbolAt(doc, doc.ScopeAt(pos), name)
+		}
+	}
+	if !ok {
+		return nil
+	}
+
+	var refs []protocol.Location
+	for _, d := range sn.All() {
+		nix.Walk(d.File.Root, func(n nix.Node) {
+			switch use := n.(type) {
+			case *nix.Ident:
+				if r, ok := sn.ResolveIdent(d, use); ok && sameBinding(r, target) {
+					refs = append(refs, protocol.Location{URI: d.URI, Range: SpanToRange(d.Text, use.Span())})
+				}
+			case *nix.Select:
+				for _, seg := range use.Path {
+					if seg.Name == "" {
+						continue
+					}
+					if r, ok := sn.ResolveSelect(d, use, seg.Span.Start); ok && sameBinding(r, target) {
+						refs = append(refs, protocol.Location{URI: d.URI, Range: SpanToRange(d.Text, seg.Span)})
+					}
+				}
+			}
+		})
+	}
+	return refs
+}
+
+// sameBinding reports whether a and b resolved to the same definition: the
+// same document and name span. Value is ignored since it's only a cached
+// convenience for Hover and doesn't bear on identity.
+func sameBinding(a, b Resolved) bool {
+	return a.Doc == b.Doc && a.Span == b.Span
+}
+
+// attrBindingNameAt reports the name bind declares in scope when pos sits on
+// its head segment — the only segment defineBindings registers as a Symbol;
+// a later static segment of `a.b.c = ...;` names nested attrset structure,
+// not a separate scope binding.
+func attrBindingNameAt(bind *nix.AttrBinding, pos nix.Position) (string, bool) {
+	if len(bind.Path) == 0 {
+		return "", false
+	}
+	head := bind.Path[0]
+	if head.Expr != nil || !head.Span.Contains(pos) {
+		return "", false
+	}
+	return head.Name, true
+}
+
+// inheritNameAt reports which of bind's inherited names contains pos.
+func inheritNameAt(bind *nix.InheritBinding, pos nix.Position) (string, bool) {
+	for _, n := range bind.Names {
+		if n.Expr == nil && n.Span.Contains(pos) {
+			return n.Name, true
+		}
+	}
+	return "", false
+}
+
+// patternParamNameAt reports which field of param contains pos, falling back
+// to its `@name` whole-argument binding when pos falls elsewhere in the
+// pattern (e.g. on the `@` itself, whose symbol spans the whole pattern).
+func patternParamNameAt(param *nix.PatternParam, pos nix.Position) (string, bool) {
+	for _, f := range param.Fields {
+		if f.Span.Contains(pos) {
+			return f.Name, true
+		}
+	}
+	if param.Bind != "" {
+		return param.Bind, true
+	}
+	return "", false
+}
@@ -0,0 +1,272 @@
+package analysis
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.lsp.dev/protocol"
+	"go.lsp.dev/uri"
+
+	"github.com/legonois/nixos-lsp/internal/nix"
+)
+
+func TestDefinitionLetBinding(t *testing.T) {
+	sess := NewSession()
+	src := `let x = 1; in x + 1`
+	u := protocol.URI("file:///t.nix")
+	snap := sess.Open(u, src, 1)
+	doc, _ := snap.Get(u)
+
+	usePos := nix.Position{Offset: len(`let x = 1; in `)}
+	locs := snap.Definition(doc, usePos)
+	if len(locs) != 1 {
+		t.Fatalf("got %d locations, want 1", len(locs))
+	}
+	wantStart := uint32(4) // column of `x` in `let x = 1;`
+	if locs[0].Range.Start.Character != wantStart {
+		t.Fatalf("def location = %#v, want column %d", locs[0], wantStart)
+	}
+}
+
+func TestPosFromLSPHandlesUTF16Columns(t *testing.T) {
+	// "😀" is one astral-plane rune: 4 UTF-8 bytes, but 2 UTF-16 code units
+	// (a surrogate pair) — the unit the LSP Position.Character counts in.
+	src := "😀x = 1;"
+	pos := PosFromLSP(protocol.Position{Line: 0, Character: 3}, src)
+	if pos.Offset != len("😀x") {
+		t.Fatalf("offset = %d, want %d (after 😀x)", pos.Offset, len("😀x"))
+	}
+}
+
+func TestSpanToRangeEncodesUTF16Columns(t *testing.T) {
+	src := "😀x = 1;"
+	sp := nix.Span{
+		Start: nix.Position{Line: 0, Column: len("😀"), Offset: len("😀")},
+		End:   nix.Position{Line: 0, Column: len("😀x"), Offset: len("😀x")},
+	}
+	r := SpanToRange(src, sp)
+	if r.Start.Character != 2 {
+		t.Fatalf("start character = %d, want 2 (after the surrogate pair)", r.Start.Character)
+	}
+	if r.End.Character != 3 {
+		t.Fatalf("end character = %d, want 3", r.End.Character)
+	}
+}
+
+func TestDefinitionWithFallback(t *testing.T) {
+	sess := NewSession()
+	src := `with { hello = 1; }; hello`
+	u := protocol.URI("file:///t.nix")
+	snap := sess.Open(u, src, 1)
+	doc, _ := snap.Get(u)
+
+	usePos := nix.Position{Offset: len(src) - 1}
+	locs := snap.Definition(doc, usePos)
+	if len(locs) != 1 {
+		t.Fatalf("got %d locations, want 1 (with-fallback)", len(locs))
+	}
+}
+
+func TestDefinitionAttrPathSelect(t *testing.T) {
+	sess := NewSession()
+	src := `let pkgs = { hello = 1; }; in pkgs.hello`
+	u := protocol.URI("file:///t.nix")
+	snap := sess.Open(u, src, 1)
+	doc, _ := snap.Get(u)
+
+	helloOffset := len(`let pkgs = { hello = 1; }; in pkgs.`)
+	pos := nix.Position{Offset: helloOffset + 1}
+	locs := snap.Definition(doc, pos)
+	if len(locs) != 1 {
+		t.Fatalf("got %d locations, want 1", len(locs))
+	}
+	wantStart := uint32(len("let pkgs = { "))
+	if locs[0].Range.Start.Character != wantStart {
+		t.Fatalf("def location = %#v, want column %d", locs[0], wantStart)
+	}
+}
+
+func TestDefinitionAcrossImport(t *testing.T) {
+	dir := t.TempDir()
+	libPath := filepath.Join(dir, "lib.nix")
+	if err := os.WriteFile(libPath, []byte(`{ greeting = "hi"; }`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	mainPath := filepath.Join(dir, "main.nix")
+	mainSrc := `let lib = import ./lib.nix; in lib.greeting`
+	if err := os.WriteFile(mainPath, []byte(mainSrc), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	sess := NewSession()
+	mainURI := uri.File(mainPath)
+	snap := sess.Open(mainURI, mainSrc, 1)
+	doc, _ := snap.Get(mainURI)
+
+	pos := nix.Position{Offset: len(`let lib = import ./lib.nix; in lib.`) + 1}
+	locs := snap.Definition(doc, pos)
+	if len(locs) != 1 {
+		t.Fatalf("got %d locations, want 1 (cross-file)", len(locs))
+	}
+	if locs[0].URI != uri.File(libPath) {
+		t.Fatalf("def URI = %v, want %v", locs[0].URI, uri.File(libPath))
+	}
+
+	// Resolving the import also expands the snapshot's metadata graph, even
+	// though this particular Snapshot value predates the expansion.
+	if got := sess.Snapshot().ImportersOf(uri.File(libPath)); len(got) != 1 || got[0] != mainURI {
+		t.Fatalf("ImportersOf(lib.nix) = %v, want [%v]", got, mainURI)
+	}
+}
+
+func TestDefinitionOnPathLiteral(t *testing.T) {
+	dir := t.TempDir()
+	libPath := filepath.Join(dir, "lib.nix")
+	if err := os.WriteFile(libPath, []byte(`{ greeting = "hi"; }`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	mainPath := filepath.Join(dir, "main.nix")
+	mainSrc := `import ./lib.nix`
+	if err := os.WriteFile(mainPath, []byte(mainSrc), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	sess := NewSession()
+	mainURI := uri.File(mainPath)
+	snap := sess.Open(mainURI, mainSrc, 1)
+	doc, _ := snap.Get(mainURI)
+
+	pos := nix.Position{Offset: len(`import ./`)}
+	locs := snap.Definition(doc, pos)
+	if len(locs) != 1 {
+		t.Fatalf("got %d locations, want 1 (cursor on the path literal itself)", len(locs))
+	}
+	if locs[0].URI != uri.File(libPath) {
+		t.Fatalf("def URI = %v, want %v", locs[0].URI, uri.File(libPath))
+	}
+}
+
+func TestDefinitionThroughMultiSegmentAttrPath(t *testing.T) {
+	sess := NewSession()
+	src := `let cfg = { services.nginx.enable = true; }; in cfg.services.nginx.enable`
+	u := protocol.URI("file:///t.nix")
+	snap := sess.Open(u, src, 1)
+	doc, _ := snap.Get(u)
+
+	useEnableOffset := len(`let cfg = { services.nginx.enable = true; }; in cfg.services.nginx.`)
+	pos := nix.Position{Offset: useEnableOffset + 1}
+	locs := snap.Definition(doc, pos)
+	if len(locs) != 1 {
+		t.Fatalf("got %d locations, want 1 (enable binding site)", len(locs))
+	}
+	wantStart := uint32(len(`let cfg = { services.nginx.`))
+	if locs[0].Range.Start.Character != wantStart {
+		t.Fatalf("def location = %#v, want column %d", locs[0], wantStart)
+	}
+}
+
+func TestDefinitionThroughMultiSegmentAttrPathMidSegment(t *testing.T) {
+	sess := NewSession()
+	src := `let cfg = { services.nginx.enable = true; }; in cfg.services.nginx.enable`
+	u := protocol.URI("file:///t.nix")
+	snap := sess.Open(u, src, 1)
+	doc, _ := snap.Get(u)
+
+	useNginxOffset := len(`let cfg = { services.nginx.enable = true; }; in cfg.services.`)
+	pos := nix.Position{Offset: useNginxOffset + 1}
+	locs := snap.Definition(doc, pos)
+	if len(locs) != 1 {
+		t.Fatalf("got %d locations, want 1 (nginx segment)", len(locs))
+	}
+	wantStart := uint32(len(`let cfg = { services.`))
+	if locs[0].Range.Start.Character != wantStart {
+		t.Fatalf("def location = %#v, want column %d", locs[0], wantStart)
+	}
+}
+
+func TestDefinitionThroughSiblingMultiSegmentAttrPaths(t *testing.T) {
+	sess := NewSession()
+	src := `let cfg = { services.nginx.enable = true; services.openssh.enable = false; }; in cfg.services.openssh.enable`
+	u := protocol.URI("file:///t.nix")
+	snap := sess.Open(u, src, 1)
+	doc, _ := snap.Get(u)
+
+	useEnableOffset := len(src) - len("enable")
+	pos := nix.Position{Offset: useEnableOffset + 1}
+	locs := snap.Definition(doc, pos)
+	if len(locs) != 1 {
+		t.Fatalf("got %d locations, want 1 (openssh.enable binding site, not lost behind the nginx sibling)", len(locs))
+	}
+	wantStart := uint32(len(`let cfg = { services.nginx.enable = true; services.openssh.`))
+	if locs[0].Range.Start.Character != wantStart {
+		t.Fatalf("def location = %#v, want column %d", locs[0], wantStart)
+	}
+}
+
+func TestReferencesFindsAllUses(t *testing.T) {
+	sess := NewSession()
+	src := `let x = 1; in x + x`
+	u := protocol.URI("file:///t.nix")
+	snap := sess.Open(u, src, 1)
+	doc, _ := snap.Get(u)
+
+	usePos := nix.Position{Offset: len(`let x = 1; in `)}
+	refs := snap.References(doc, usePos)
+	if len(refs) != 2 {
+		t.Fatalf("got %d references, want 2 (both uses of x)", len(refs))
+	}
+}
+
+func TestReferencesFromDeclarationSite(t *testing.T) {
+	sess := NewSession()
+	src := `let x = 1; in x + x`
+	u := protocol.URI("file:///t.nix")
+	snap := sess.Open(u, src, 1)
+	doc, _ := snap.Get(u)
+
+	declPos := nix.Position{Offset: len(`let `)} // on the declaring `x` itself
+	refs := snap.References(doc, declPos)
+	if len(refs) != 2 {
+		t.Fatalf("got %d references from the declaration site, want 2 (both uses)", len(refs))
+	}
+}
+
+func TestReferencesAttrPathInverse(t *testing.T) {
+	sess := NewSession()
+	src := `let pkgs = { hello = 1; }; in [ pkgs.hello pkgs.hello ]`
+	u := protocol.URI("file:///t.nix")
+	snap := sess.Open(u, src, 1)
+	doc, _ := snap.Get(u)
+
+	firstUsePos := nix.Position{Offset: len(`let pkgs = { hello = 1; }; in [ pkgs.`) + 1}
+	refs := snap.References(doc, firstUsePos)
+	if len(refs) != 2 {
+		t.Fatalf("got %d references, want 2 (both pkgs.hello selects)", len(refs))
+	}
+}
+
+func TestSnapshotFileSetAndImporters(t *testing.T) {
+	dir := t.TempDir()
+	libPath := filepath.Join(dir, "lib.nix")
+	if err := os.WriteFile(libPath, []byte(`{ greeting = "hi"; }`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	mainPath := filepath.Join(dir, "main.nix")
+	mainSrc := `(import ./lib.nix).greeting`
+	if err := os.WriteFile(mainPath, []byte(mainSrc), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	sess := NewSession()
+	mainURI := uri.File(mainPath)
+	snap := sess.Open(mainURI, mainSrc, 1)
+	doc, _ := snap.Get(mainURI)
+	greetingPos := nix.Position{Offset: len(`(import ./lib.nix).`) + 1}
+	snap.Definition(doc, greetingPos) // force lib.nix to load
+
+	files := sess.Snapshot().FileSet()
+	if len(files) != 2 {
+		t.Fatalf("FileSet() = %v, want 2 entries", files)
+	}
+}
@@ -0,0 +1,289 @@
+package analysis
+
+import "github.com/legonois/nixos-lsp/internal/nix"
+
+// SymbolKind classifies how a Symbol came to be in scope.
+type SymbolKind int
+
+const (
+	// SymbolLet is a `let name = value; in ...` binding.
+	SymbolLet SymbolKind = iota
+	// SymbolRecAttr is a member of a `rec { name = value; }` attrset.
+	SymbolRecAttr
+	// SymbolInherit is `inherit name;` or `inherit (from) name;`.
+	SymbolInherit
+	// SymbolParam is a function parameter: a plain `name:` argument, one
+	// field of a `{ name, ... }:` pattern, or the `@name` whole-argument
+	// binding.
+	SymbolParam
+)
+
+// Symbol is one name bound in a Scope.
+type Symbol struct {
+	Name     string
+	Kind     SymbolKind
+	NameSpan nix.Span // span of the name itself, for go-to-definition
+	Value    nix.Expr // bound expression; nil for plain params and inherits without a resolvable source
+	From     nix.Expr // for SymbolInherit with an explicit `(from)`; nil otherwise
+}
+
+// Scope is one lexical scope: the set of names introduced by a single let,
+// function, rec attrset, or with, plus a link to its enclosing scope.
+type Scope struct {
+	Parent   *Scope
+	Span     nix.Span
+	Symbols  map[string]*Symbol
+	Children []*Scope
+
+	// With is non-nil when this scope was introduced by `with expr; body`;
+	// callers that fail a static Lookup can try resolving names against it
+	// dynamically (e.g. by evaluating expr if it's a literal attrset).
+	With nix.Expr
+}
+
+func newScope(parent *Scope, span nix.Span) *Scope {
+	s := &Scope{Parent: parent, Span: span, Symbols: make(map[string]*Symbol)}
+	if parent != nil {
+		parent.Children = append(parent.Children, s)
+	}
+	return s
+}
+
+func (s *Scope) define(sym *Symbol) { s.Symbols[sym.Name] = sym }
+
+// Lookup searches s and its ancestors for name, returning the nearest
+// (innermost) binding.
+func (s *Scope) Lookup(name string) (*Symbol, *Scope, bool) {
+	for cur := s; cur != nil; cur = cur.Parent {
+		if sym, ok := cur.Symbols[name]; ok {
+			return sym, cur, true
+		}
+	}
+	return nil, nil, false
+}
+
+// Withs returns every `with` target expression visible from s, innermost
+// first, for dynamic fallback resolution.
+func (s *Scope) Withs() []nix.Expr {
+	var out []nix.Expr
+	for cur := s; cur != nil; cur = cur.Parent {
+		if cur.With != nil {
+			out = append(out, cur.With)
+		}
+	}
+	return out
+}
+
+// ScopeAt returns the innermost scope in the document whose span contains
+// pos, which is used to seed name resolution at a cursor location.
+func (d *Document) ScopeAt(pos nix.Position) *Scope {
+	best := d.Root
+	var walk func(s *Scope)
+	walk = func(s *Scope) {
+		for _, c := range s.Children {
+			if spanContains(c.Span, pos) {
+				best = c
+				walk(c)
+				return
+			}
+		}
+	}
+	walk(d.Root)
+	return best
+}
+
+func spanContains(sp nix.Span, pos nix.Position) bool {
+	if pos.Offset < sp.Start.Offset || pos.Offset > sp.End.Offset {
+		return false
+	}
+	return true
+}
+
+// buildScope walks e, creating a child Scope at every let/function/rec
+// attrset/with boundary and populating it with the names that construct
+// introduces, recursing into subexpressions with whichever scope is
+// lexically in effect at that point.
+func (d *Document) buildScope(e nix.Expr, s *Scope) {
+	if e == nil {
+		return
+	}
+	switch n := e.(type) {
+	case *nix.LetIn:
+		child := d.newChildScope(s, n.Span())
+		d.defineBindings(n.Bindings, child, SymbolLet, child)
+		d.buildScope(n.Body, child)
+
+	case *nix.With:
+		d.buildScope(n.Expr, s)
+		child := d.newChildScope(s, n.Span())
+		child.With = n.Expr
+		d.buildScope(n.Body, child)
+
+	case *nix.Function:
+		child := d.newChildScope(s, n.Span())
+		d.defineParam(n.Param, child)
+		d.buildScope(n.Body, child)
+
+	case *nix.AttrSet:
+		if n.Rec {
+			child := d.newChildScope(s, n.Span())
+			d.defineBindings(n.Bindings, child, SymbolRecAttr, child)
+		} else {
+			for _, b := range n.Bindings {
+				d.buildBindingValue(b, s)
+			}
+		}
+
+	case *nix.Apply:
+		d.buildScope(n.Fn, s)
+		d.buildScope(n.Arg, s)
+
+	case *nix.UnaryOp:
+		d.buildScope(n.Expr, s)
+
+	case *nix.BinaryOp:
+		d.buildScope(n.Left, s)
+		d.buildScope(n.Right, s)
+
+	case *nix.If:
+		d.buildScope(n.Cond, s)
+		d.buildScope(n.Then, s)
+		d.buildScope(n.Else, s)
+
+	case *nix.Assert:
+		d.buildScope(n.Cond, s)
+		d.buildScope(n.Body, s)
+
+	case *nix.Select:
+		d.buildScope(n.Expr, s)
+		d.buildPathExprs(n.Path, s)
+		d.buildScope(n.Default, s)
+
+	case *nix.HasAttr:
+		d.buildScope(n.Expr, s)
+		d.buildPathExprs(n.Path, s)
+
+	case *nix.ListExpr:
+		for _, el := range n.Elems {
+			d.buildScope(el, s)
+		}
+
+	case *nix.Str:
+		for _, part := range n.Parts {
+			if part.Interp != nil {
+				d.buildScope(part.Interp, s)
+			}
+		}
+
+	default:
+		// Literals (Ident, Int, Float, Bool, Null, PathLit, SearchPath,
+		// URILit) introduce and contain no further sub-expressions.
+	}
+}
+
+func (d *Document) newChildScope(parent *Scope, span nix.Span) *Scope {
+	c := newScope(parent, span)
+	d.scopes = append(d.scopes, c)
+	return c
+}
+
+// attrHead accumulates everything that binds a given head name across a
+// single bindings list, so sibling multi-segment bindings that share a head
+// (e.g. `services.nginx.enable = ...;` alongside `services.openssh.enable =
+// ...;` in the same set) all end up reachable through that one head symbol,
+// instead of whichever binding happened to be registered last.
+type attrHead struct {
+	span     nix.Span
+	value    nix.Expr // set when some binding fully assigns this name (len(Path) == 1)
+	suffixes []nix.Binding
+}
+
+func (d *Document) defineBindings(bindings []nix.Binding, scope *Scope, kind SymbolKind, valueScope *Scope) {
+	heads := map[string]*attrHead{}
+	var order []string
+
+	for _, b := range bindings {
+		d.buildBindingValue(b, valueScope)
+		switch bind := b.(type) {
+		case *nix.AttrBinding:
+			if len(bind.Path) == 0 {
+				continue
+			}
+			head := bind.Path[0]
+			if head.Expr != nil {
+				continue // dynamic `${...}` attr name can't be statically bound
+			}
+			h, ok := heads[head.Name]
+			if !ok {
+				h = &attrHead{span: head.Span}
+				heads[head.Name] = h
+				order = append(order, head.Name)
+			}
+			if len(bind.Path) == 1 {
+				h.value = bind.Value
+			} else {
+				h.suffixes = append(h.suffixes, &nix.AttrBinding{Path: bind.Path[1:], Value: bind.Value})
+			}
+		case *nix.InheritBinding:
+			for _, name := range bind.Names {
+				if name.Expr != nil {
+					continue
+				}
+				scope.define(&Symbol{Name: name.Name, Kind: SymbolInherit, NameSpan: name.Span, From: bind.From})
+			}
+		}
+	}
+
+	for _, name := range order {
+		h := heads[name]
+		sym := &Symbol{Name: name, Kind: kind, NameSpan: h.span}
+		switch {
+		case h.value != nil:
+			sym.Value = h.value
+		case len(h.suffixes) > 0:
+			// `services.nginx.enable = ...;` binds `services` to a synthetic
+			// nested view of the rest of each sibling path, so chasing
+			// through `services` as an ordinary identifier still reaches
+			// every branch one segment at a time.
+			sym.Value = &nix.AttrSet{Bindings: h.suffixes}
+		}
+		scope.define(sym)
+	}
+}
+
+func (d *Document) buildBindingValue(b nix.Binding, s *Scope) {
+	switch bind := b.(type) {
+	case *nix.AttrBinding:
+		d.buildPathExprs(bind.Path, s)
+		d.buildScope(bind.Value, s)
+	case *nix.InheritBinding:
+		if bind.From != nil {
+			d.buildScope(bind.From, s)
+		}
+	}
+}
+
+func (d *Document) buildPathExprs(path []nix.AttrPathSegment, s *Scope) {
+	for _, seg := range path {
+		if seg.Expr != nil {
+			d.buildScope(seg.Expr, s)
+		}
+	}
+}
+
+func (d *Document) defineParam(p nix.Param, scope *Scope) {
+	switch param := p.(type) {
+	case *nix.IdentParam:
+		scope.define(&Symbol{Name: param.Name, Kind: SymbolParam, NameSpan: param.Span()})
+	case *nix.PatternParam:
+		for _, f := range param.Fields {
+			scope.define(&Symbol{Name: f.Name, Kind: SymbolParam, NameSpan: f.Span, Value: f.Default})
+			if f.Default != nil {
+				d.buildScope(f.Default, scope)
+			}
+		}
+		if param.Bind != "" {
+			scope.define(&Symbol{Name: param.Bind, Kind: SymbolParam, NameSpan: param.Span()})
+		}
+	}
+}
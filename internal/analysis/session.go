@@ -0,0 +1,173 @@
+package analysis
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"go.lsp.dev/protocol"
+	"go.lsp.dev/uri"
+
+	"github.com/legonois/nixos-lsp/internal/nix"
+)
+
+// Session owns the server's view of the workspace over time. Every edit
+// produces a new immutable Snapshot rather than mutating one in place: open
+// a file, and the Session reparses just that file, recomputes the import
+// graph, and publishes a fresh Snapshot for handlers to read without
+// locking. Files pulled in on demand while resolving `import` (or
+// cross-file `inherit (from)`) are cached in a disk-backed layer shared by
+// every Snapshot, so repeated lookups don't re-read and re-parse them.
+type Session struct {
+	mu       sync.Mutex
+	openDocs map[protocol.URI]*Document
+	diskDocs map[protocol.URI]*Document
+	snapshot *Snapshot
+}
+
+// NewSession creates an empty Session with no open or cached documents.
+func NewSession() *Session {
+	s := &Session{
+		openDocs: make(map[protocol.URI]*Document),
+		diskDocs: make(map[protocol.URI]*Document),
+	}
+	s.snapshot = s.buildSnapshot("")
+	return s
+}
+
+// Open records the client's view of uri's contents, analyzes it, and
+// returns the new Snapshot produced by the edit. Any stale disk-loaded
+// cache entry for uri is dropped: the client's buffer now takes priority.
+func (s *Session) Open(u protocol.URI, text string, version int32) *Snapshot {
+	doc := Parse(u, text, version)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.openDocs[u] = doc
+	delete(s.diskDocs, u)
+	s.snapshot = s.buildSnapshot(u)
+	return s.snapshot
+}
+
+// Snapshot returns the Session's current, immutable view of the workspace.
+func (s *Session) Snapshot() *Snapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.snapshot
+}
+
+// loadDisk returns the Document for uri, reading and parsing it from disk
+// on a cache miss, and folding it into the shared disk cache so later
+// Snapshots see it in their import graph too. It never overwrites a
+// document the client already has open.
+func (s *Session) loadDisk(u protocol.URI) (*Document, bool) {
+	s.mu.Lock()
+	if d, ok := s.openDocs[u]; ok {
+		s.mu.Unlock()
+		return d, true
+	}
+	if d, ok := s.diskDocs[u]; ok {
+		s.mu.Unlock()
+		return d, true
+	}
+	s.mu.Unlock()
+
+	text, err := os.ReadFile(protocol.URI(u).Filename())
+	if err != nil {
+		return nil, false
+	}
+	d := Parse(u, string(text), 0)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if existing, ok := s.diskDocs[u]; ok {
+		return existing, true
+	}
+	s.diskDocs[u] = d
+	s.snapshot = s.buildSnapshot(u)
+	return d, true
+}
+
+// buildSnapshot assembles a new Snapshot from the Session's current open
+// and disk-cached documents. Only changed's import edges are recomputed by
+// walking its AST; every other document reuses whatever the previous
+// snapshot already computed for it, so an edit to one file in a large
+// workspace doesn't re-walk every other file's AST. Callers must hold s.mu.
+func (s *Session) buildSnapshot(changed protocol.URI) *Snapshot {
+	docs := make(map[protocol.URI]*Document, len(s.openDocs)+len(s.diskDocs))
+	for u, d := range s.diskDocs {
+		docs[u] = d
+	}
+	for u, d := range s.openDocs {
+		docs[u] = d // an open buffer always wins over a stale disk read
+	}
+
+	imports := make(map[protocol.URI][]protocol.URI, len(docs))
+	for u, d := range docs {
+		if u != changed {
+			if prev, ok := s.snapshot.importsFor(u); ok {
+				imports[u] = prev
+				continue
+			}
+		}
+		imports[u] = importEdges(d)
+	}
+	importedBy := make(map[protocol.URI][]protocol.URI)
+	for u, targets := range imports {
+		for _, t := range targets {
+			importedBy[t] = append(importedBy[t], u)
+		}
+	}
+
+	return &Snapshot{
+		session:    s,
+		docs:       docs,
+		imports:    imports,
+		importedBy: importedBy,
+	}
+}
+
+// importEdges collects the set of files d statically imports via
+// `import ./other.nix`, resolved to absolute URIs. It only looks at the
+// literal path argument; a dynamically computed import target can't be
+// graphed without evaluation and is simply not an edge.
+func importEdges(d *Document) []protocol.URI {
+	var edges []protocol.URI
+	nix.Walk(d.File.Root, func(n nix.Node) {
+		app, ok := n.(*nix.Apply)
+		if !ok {
+			return
+		}
+		fn, ok := app.Fn.(*nix.Ident)
+		if !ok || fn.Name != "import" {
+			return
+		}
+		path, ok := app.Arg.(*nix.PathLit)
+		if !ok {
+			return
+		}
+		edges = append(edges, ResolveRelativePath(d.URI, path.Value))
+	})
+	return edges
+}
+
+// ResolveRelativePath resolves a Nix path literal (e.g. `./foo.nix`,
+// `../lib`) against the directory containing fromURI, applying the same
+// `default.nix` fallback Nix itself uses when a path names a directory.
+func ResolveRelativePath(fromURI protocol.URI, rel string) protocol.URI {
+	base := filepath.Dir(protocol.URI(fromURI).Filename())
+	p := rel
+	if strings.HasPrefix(p, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			p = filepath.Join(home, p[2:])
+		}
+	} else if !filepath.IsAbs(p) {
+		p = filepath.Join(base, p)
+	}
+	if !strings.HasSuffix(p, ".nix") {
+		if info, err := os.Stat(p); err == nil && info.IsDir() {
+			p = filepath.Join(p, "default.nix")
+		}
+	}
+	return uri.File(p)
+}
@@ -0,0 +1,36 @@
+package analysis
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.lsp.dev/uri"
+)
+
+// TestBuildSnapshotReusesUnchangedImportEdges guards the fix for buildSnapshot
+// re-walking every open document's AST on every edit: only the just-edited
+// document's import edges should be recomputed, and every other document
+// should keep reusing whatever the previous snapshot already computed for it.
+func TestBuildSnapshotReusesUnchangedImportEdges(t *testing.T) {
+	dir := t.TempDir()
+	libPath := filepath.Join(dir, "lib.nix")
+	if err := os.WriteFile(libPath, []byte(`{ greeting = "hi"; }`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	sess := NewSession()
+	aURI := uri.File(filepath.Join(dir, "a.nix"))
+	bURI := uri.File(filepath.Join(dir, "b.nix"))
+
+	sess.Open(aURI, `import ./lib.nix`, 1)
+	before := sess.Open(bURI, `1 + 1`, 1).imports[aURI]
+	if len(before) == 0 {
+		t.Fatal("a.nix's import edges = none, want one edge into lib.nix")
+	}
+
+	after := sess.Open(bURI, `2 + 2`, 2).imports[aURI]
+	if &before[0] != &after[0] {
+		t.Fatal("editing b.nix recomputed a.nix's import edges; want them reused from the previous snapshot")
+	}
+}
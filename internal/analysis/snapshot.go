@@ -0,0 +1,79 @@
+package analysis
+
+import "go.lsp.dev/protocol"
+
+// Snapshot is a read-only, point-in-time view of the workspace: the set of
+// documents the Session knew about at the moment it was built, plus the
+// import graph derived from them. It never changes after construction —
+// handling a request against a Snapshot can't race with the next edit being
+// applied to the Session.
+type Snapshot struct {
+	session    *Session
+	docs       map[protocol.URI]*Document
+	imports    map[protocol.URI][]protocol.URI
+	importedBy map[protocol.URI][]protocol.URI
+}
+
+// Get returns the Document for uri if the snapshot has it open or cached.
+func (sn *Snapshot) Get(u protocol.URI) (*Document, bool) {
+	d, ok := sn.docs[u]
+	return d, ok
+}
+
+// FileSet returns every URI the snapshot knows about, in no particular
+// order. It is the scan set for find-references: a best-effort workspace
+// view (open files, plus whatever's been pulled in via `import` so far)
+// rather than a full project index.
+func (sn *Snapshot) FileSet() []protocol.URI {
+	out := make([]protocol.URI, 0, len(sn.docs))
+	for u := range sn.docs {
+		out = append(out, u)
+	}
+	return out
+}
+
+// ImportersOf returns the URIs of every document that statically imports
+// uri, i.e. uri's reverse dependencies. A future diagnostics or hover cache
+// can use this to invalidate exactly the documents affected by an edit to
+// uri, instead of recomputing the whole workspace.
+func (sn *Snapshot) ImportersOf(u protocol.URI) []protocol.URI {
+	edges := sn.importedBy[u]
+	if len(edges) == 0 {
+		return nil
+	}
+	out := make([]protocol.URI, len(edges))
+	copy(out, edges)
+	return out
+}
+
+// importsFor returns the import edges sn previously computed for u, for
+// buildSnapshot to reuse without re-walking u's AST. Safe to call on a nil
+// Snapshot: the very first Snapshot a Session builds has no predecessor to
+// reuse from, so every document's edges are computed fresh.
+func (sn *Snapshot) importsFor(u protocol.URI) ([]protocol.URI, bool) {
+	if sn == nil {
+		return nil, false
+	}
+	edges, ok := sn.imports[u]
+	return edges, ok
+}
+
+// getOrLoad returns the Document for uri, falling back to the Session's
+// shared disk cache (reading and parsing the file on a cache miss) when the
+// snapshot itself doesn't have it — e.g. an `import` target that's never
+// been opened or crossed before.
+func (sn *Snapshot) getOrLoad(u protocol.URI) (*Document, bool) {
+	if d, ok := sn.Get(u); ok {
+		return d, true
+	}
+	return sn.session.loadDisk(u)
+}
+
+// All returns every Document in the snapshot, in no particular order.
+func (sn *Snapshot) All() []*Document {
+	out := make([]*Document, 0, len(sn.docs))
+	for _, d := range sn.docs {
+		out = append(out, d)
+	}
+	return out
+}
@@ -0,0 +1,161 @@
+// Package completion turns a cursor position into textDocument/completion
+// candidates: in-scope identifiers (let bindings, function params, rec
+// attrset members, `with expr;` targets) plus, inside an attribute-path
+// context like `pkgs.<cursor>` or `lib.<cursor>`, entries from a
+// pre-built nixpkgs attribute index.
+package completion
+
+import (
+	"strings"
+
+	"go.lsp.dev/protocol"
+
+	"github.com/legonois/nixos-lsp/internal/analysis"
+	"github.com/legonois/nixos-lsp/internal/nix"
+	"github.com/legonois/nixos-lsp/internal/nixpkgs"
+)
+
+// Provider answers completion requests using a Snapshot's scope analysis
+// and a nixpkgs.Provider's attribute index.
+type Provider struct {
+	index *nixpkgs.Provider
+}
+
+// New creates a Provider backed by index.
+func New(index *nixpkgs.Provider) *Provider {
+	return &Provider{index: index}
+}
+
+// Complete returns the completion items for pos in doc.
+func (p *Provider) Complete(snap *analysis.Snapshot, doc *analysis.Document, pos nix.Position) []protocol.CompletionItem {
+	path, prefix := attrContext(doc.Text, pos)
+	if len(path) > 0 {
+		if items := p.completeAttrPath(snap, doc, pos, path, prefix); items != nil {
+			return items
+		}
+	}
+	return p.completeScope(snap, doc, pos, prefix)
+}
+
+// completeAttrPath resolves path (everything before the segment under the
+// cursor) to an attrset, preferring a local, statically-known binding and
+// falling back to the nixpkgs index for the two entry points it actually
+// indexes: `pkgs` and `lib`.
+func (p *Provider) completeAttrPath(snap *analysis.Snapshot, doc *analysis.Document, pos nix.Position, path []string, prefix string) []protocol.CompletionItem {
+	if set, _, ok := snap.LookupAttrPath(doc, pos, path); ok {
+		return scopeMatches(set.Names(), prefix)
+	}
+
+	idx := p.index.Index()
+	if idx == nil {
+		return nil
+	}
+	switch path[0] {
+	case "pkgs":
+		return attrMatches(idx.ChildrenOf(strings.Join(path[1:], ".")), prefix)
+	case "lib":
+		return attrMatches(idx.ChildrenOf(strings.Join(path, ".")), prefix)
+	}
+	return nil
+}
+
+// completeScope offers every name visible at pos: the enclosing scope
+// chain's own bindings, plus the members of any statically-resolvable
+// `with expr;` target.
+func (p *Provider) completeScope(snap *analysis.Snapshot, doc *analysis.Document, pos nix.Position, prefix string) []protocol.CompletionItem {
+	scope := doc.ScopeAt(pos)
+	seen := make(map[string]bool)
+	var items []protocol.CompletionItem
+
+	for s := scope; s != nil; s = s.Parent {
+		for name, sym := range s.Symbols {
+			if seen[name] || !strings.HasPrefix(name, prefix) {
+				continue
+			}
+			seen[name] = true
+			items = append(items, protocol.CompletionItem{
+				Label:      name,
+				Kind:       symbolCompletionKind(sym.Kind),
+				InsertText: name,
+			})
+		}
+	}
+
+	for _, w := range scope.Withs() {
+		set, _, ok := snap.ResolveAttrSet(doc, w)
+		if !ok {
+			continue
+		}
+		for _, name := range set.Names() {
+			if seen[name] || !strings.HasPrefix(name, prefix) {
+				continue
+			}
+			seen[name] = true
+			items = append(items, protocol.CompletionItem{
+				Label:      name,
+				Kind:       protocol.CompletionItemKindField,
+				InsertText: name,
+			})
+		}
+	}
+
+	return items
+}
+
+func symbolCompletionKind(kind analysis.SymbolKind) protocol.CompletionItemKind {
+	switch kind {
+	case analysis.SymbolParam:
+		return protocol.CompletionItemKindVariable
+	case analysis.SymbolRecAttr, analysis.SymbolInherit:
+		return protocol.CompletionItemKindField
+	default:
+		return protocol.CompletionItemKindVariable
+	}
+}
+
+func scopeMatches(names []string, prefix string) []protocol.CompletionItem {
+	var items []protocol.CompletionItem
+	for _, name := range names {
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		items = append(items, protocol.CompletionItem{
+			Label:      name,
+			Kind:       protocol.CompletionItemKindField,
+			InsertText: name,
+		})
+	}
+	return items
+}
+
+func attrMatches(attrs []nixpkgs.Attr, prefix string) []protocol.CompletionItem {
+	var items []protocol.CompletionItem
+	for _, a := range attrs {
+		name := a.Name()
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		item := protocol.CompletionItem{
+			Label:      name,
+			Kind:       attrCompletionKind(a.Kind),
+			Detail:     a.Detail(),
+			InsertText: name,
+		}
+		if a.Description != "" {
+			item.Documentation = protocol.MarkupContent{Kind: protocol.Markdown, Value: a.Description}
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+func attrCompletionKind(kind nixpkgs.Kind) protocol.CompletionItemKind {
+	switch kind {
+	case nixpkgs.KindSet:
+		return protocol.CompletionItemKindModule
+	case nixpkgs.KindPackage:
+		return protocol.CompletionItemKindClass
+	default:
+		return protocol.CompletionItemKindValue
+	}
+}
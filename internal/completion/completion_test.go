@@ -0,0 +1,107 @@
+package completion
+
+import (
+	"testing"
+
+	"go.lsp.dev/protocol"
+
+	"github.com/legonois/nixos-lsp/internal/analysis"
+	"github.com/legonois/nixos-lsp/internal/nix"
+	"github.com/legonois/nixos-lsp/internal/nixpkgs"
+)
+
+func open(t *testing.T, src string) (*analysis.Snapshot, *analysis.Document) {
+	t.Helper()
+	sess := analysis.NewSession()
+	u := protocol.URI("file:///t.nix")
+	snap := sess.Open(u, src, 1)
+	doc, _ := snap.Get(u)
+	return snap, doc
+}
+
+func labels(items []protocol.CompletionItem) map[string]bool {
+	out := make(map[string]bool, len(items))
+	for _, it := range items {
+		out[it.Label] = true
+	}
+	return out
+}
+
+func TestCompleteScopeBindings(t *testing.T) {
+	src := "let hello = 1; helper = 2; in he"
+	snap, doc := open(t, src)
+	p := New(nixpkgs.NewStaticProvider(nil))
+
+	items := p.Complete(snap, doc, nix.Position{Offset: len(src)})
+	got := labels(items)
+	if !got["hello"] || !got["helper"] {
+		t.Fatalf("labels = %v, want hello and helper", got)
+	}
+}
+
+func TestCompleteWithTargetMembers(t *testing.T) {
+	src := "with { hello = 1; world = 2; }; he"
+	snap, doc := open(t, src)
+	p := New(nixpkgs.NewStaticProvider(nil))
+
+	items := p.Complete(snap, doc, nix.Position{Offset: len(src)})
+	got := labels(items)
+	if !got["hello"] {
+		t.Fatalf("labels = %v, want hello from the with-target", got)
+	}
+}
+
+func TestCompleteAttrPathLocalSet(t *testing.T) {
+	src := "let pkgs = { hello = 1; hey = 2; }; in pkgs.he"
+	snap, doc := open(t, src)
+	p := New(nixpkgs.NewStaticProvider(nil))
+
+	items := p.Complete(snap, doc, nix.Position{Offset: len(src)})
+	got := labels(items)
+	if !got["hello"] || !got["hey"] {
+		t.Fatalf("labels = %v, want hello and hey", got)
+	}
+}
+
+func TestCompleteAttrPathFallsBackToIndex(t *testing.T) {
+	idx := &nixpkgs.Index{
+		Packages: map[string]nixpkgs.Attr{
+			"hello": {Path: "hello", Kind: nixpkgs.KindPackage, Version: "2.12.1", Description: "A friendly program"},
+		},
+	}
+	src := "pkgs.hel"
+	snap, doc := open(t, src)
+	p := New(nixpkgs.NewStaticProvider(idx))
+
+	items := p.Complete(snap, doc, nix.Position{Offset: len(src)})
+	if len(items) != 1 {
+		t.Fatalf("got %d items, want 1: %#v", len(items), items)
+	}
+	item := items[0]
+	if item.Label != "hello" {
+		t.Fatalf("label = %q, want hello", item.Label)
+	}
+	if item.Detail != "hello 2.12.1" {
+		t.Fatalf("detail = %q", item.Detail)
+	}
+	doc2, ok := item.Documentation.(protocol.MarkupContent)
+	if !ok || doc2.Value != "A friendly program" {
+		t.Fatalf("documentation = %#v", item.Documentation)
+	}
+}
+
+func TestCompleteLibFallsBackToIndex(t *testing.T) {
+	idx := &nixpkgs.Index{LibAttrs: map[string]nixpkgs.Attr{
+		"strings":  {Path: "strings", Kind: nixpkgs.KindSet},
+		"attrsets": {Path: "attrsets", Kind: nixpkgs.KindSet},
+	}}
+	src := "lib.str"
+	snap, doc := open(t, src)
+	p := New(nixpkgs.NewStaticProvider(idx))
+
+	items := p.Complete(snap, doc, nix.Position{Offset: len(src)})
+	got := labels(items)
+	if !got["strings"] {
+		t.Fatalf("labels = %v, want strings", got)
+	}
+}
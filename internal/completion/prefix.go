@@ -0,0 +1,52 @@
+package completion
+
+import "github.com/legonois/nixos-lsp/internal/nix"
+
+// attrContext re-tokenizes the text of pos's current line up to the cursor
+// and splits the dotted identifier chain ending there into the attribute
+// path before the cursor (e.g. ["pkgs", "lib"] for `pkgs.lib.<cursor>`) and
+// the partial identifier being typed at the cursor itself. It works from
+// raw tokens rather than the parsed AST so it still produces a sensible
+// result while the surrounding expression is mid-edit and doesn't parse.
+func attrContext(text string, pos nix.Position) (path []string, prefix string) {
+	lineStart := pos.Offset
+	for lineStart > 0 && text[lineStart-1] != '\n' {
+		lineStart--
+	}
+	toks := nix.Tokenize(text[lineStart:pos.Offset])
+	if n := len(toks); n > 0 && toks[n-1].Kind == nix.TokenEOF {
+		toks = toks[:n-1]
+	}
+	if len(toks) == 0 {
+		return nil, ""
+	}
+
+	n := len(toks)
+	trailingDot := toks[n-1].Kind == nix.TokenDot
+	if trailingDot {
+		n--
+	}
+
+	var segments []string
+	j := n
+	for j > 0 && toks[j-1].Kind == nix.TokenIdent {
+		segments = append([]string{toks[j-1].Text}, segments...)
+		j--
+		if j > 0 && toks[j-1].Kind == nix.TokenDot {
+			j--
+			continue
+		}
+		break
+	}
+
+	if trailingDot {
+		return segments, ""
+	}
+	if len(segments) == 0 {
+		return nil, ""
+	}
+	if len(segments) == 1 {
+		return nil, segments[0]
+	}
+	return segments[:len(segments)-1], segments[len(segments)-1]
+}
@@ -0,0 +1,51 @@
+package completion
+
+import (
+	"testing"
+
+	"github.com/legonois/nixos-lsp/internal/nix"
+)
+
+func offset(text string) nix.Position {
+	return nix.Position{Offset: len(text)}
+}
+
+func TestAttrContextDottedTrailing(t *testing.T) {
+	src := "pkgs."
+	path, prefix := attrContext(src, offset(src))
+	if len(path) != 1 || path[0] != "pkgs" || prefix != "" {
+		t.Fatalf("path=%v prefix=%q, want [pkgs] \"\"", path, prefix)
+	}
+}
+
+func TestAttrContextPartialIdent(t *testing.T) {
+	src := "pkgs.hel"
+	path, prefix := attrContext(src, offset(src))
+	if len(path) != 1 || path[0] != "pkgs" || prefix != "hel" {
+		t.Fatalf("path=%v prefix=%q, want [pkgs] \"hel\"", path, prefix)
+	}
+}
+
+func TestAttrContextNestedPath(t *testing.T) {
+	src := "lib.strings.hasP"
+	path, prefix := attrContext(src, offset(src))
+	if len(path) != 2 || path[0] != "lib" || path[1] != "strings" || prefix != "hasP" {
+		t.Fatalf("path=%v prefix=%q, want [lib strings] \"hasP\"", path, prefix)
+	}
+}
+
+func TestAttrContextPlainIdentNoPath(t *testing.T) {
+	src := "with pkgs; hel"
+	path, prefix := attrContext(src, offset(src))
+	if path != nil || prefix != "hel" {
+		t.Fatalf("path=%v prefix=%q, want nil \"hel\"", path, prefix)
+	}
+}
+
+func TestAttrContextEmptyLine(t *testing.T) {
+	src := "with pkgs; "
+	path, prefix := attrContext(src, offset(src))
+	if path != nil || prefix != "" {
+		t.Fatalf("path=%v prefix=%q, want nil \"\"", path, prefix)
+	}
+}
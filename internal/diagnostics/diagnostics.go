@@ -0,0 +1,50 @@
+// Package diagnostics shells out to nix-instantiate to catch syntax and
+// evaluation errors nix itself knows about but this server's own parser
+// and analysis don't (undefined variables, infinite recursion, and the
+// like), publishing the result as textDocument/publishDiagnostics
+// notifications.
+package diagnostics
+
+import (
+	"time"
+
+	"go.lsp.dev/protocol"
+)
+
+// Config controls how the Worker invokes nix-instantiate and which
+// severities it assigns to the diagnostics it produces.
+type Config struct {
+	// NixInstantiate is the binary to run; defaults to "nix-instantiate"
+	// and is resolved via PATH.
+	NixInstantiate string
+	// Debounce is how long the Worker waits after an edit before running
+	// `--parse`, so a burst of keystrokes triggers one pass, not one per
+	// keystroke.
+	Debounce time.Duration
+	// ParseSeverity is the severity assigned to `--parse` failures.
+	ParseSeverity protocol.DiagnosticSeverity
+	// EvalSeverity is the severity assigned to `--eval --strict` failures.
+	EvalSeverity protocol.DiagnosticSeverity
+	// EvalOnSave additionally runs `--eval --strict` when a document is
+	// saved, surfacing evaluator errors that `--parse` can't catch.
+	EvalOnSave bool
+}
+
+// DefaultConfig returns the settings the server uses unless overridden via
+// initializationOptions.
+func DefaultConfig() Config {
+	return Config{
+		NixInstantiate: "nix-instantiate",
+		Debounce:       300 * time.Millisecond,
+		ParseSeverity:  protocol.DiagnosticSeverityError,
+		EvalSeverity:   protocol.DiagnosticSeverityError,
+		EvalOnSave:     true,
+	}
+}
+
+// Publisher is the subset of the LSP client notifications the Worker needs;
+// server implements it by wrapping its jsonrpc2.Conn so this package stays
+// decoupled from the transport.
+type Publisher interface {
+	PublishDiagnostics(uri protocol.URI, diagnostics []protocol.Diagnostic)
+}
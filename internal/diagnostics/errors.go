@@ -0,0 +1,65 @@
+package diagnostics
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"go.lsp.dev/protocol"
+)
+
+// errorRe matches the start of a nix-instantiate error report, e.g.
+// "error: undefined variable 'foo'".
+var errorRe = regexp.MustCompile(`^error:\s*(.*)$`)
+
+// locationRe matches the "at <file>:<line>:<col>" line nix-instantiate
+// prints for the error immediately above it, in both the older single-line
+// format (`error: msg at file:3:5`) and the newer boxed format where it's
+// its own line.
+var locationRe = regexp.MustCompile(`at (?:[^ ]+):(\d+):(\d+):?\s*$`)
+
+// parseErrors scrapes nix-instantiate's stderr for "error: ... at
+// file:line:col" reports and converts each into a protocol.Diagnostic. Nix
+// doesn't emit machine-readable diagnostics, so this is necessarily a
+// best-effort text scrape rather than a real parse of its output.
+func parseErrors(stderr string, severity protocol.DiagnosticSeverity) []protocol.Diagnostic {
+	var diags []protocol.Diagnostic
+	var pending string
+
+	flush := func(line, col int) {
+		if pending == "" {
+			return
+		}
+		diags = append(diags, protocol.Diagnostic{
+			Range: protocol.Range{
+				Start: protocol.Position{Line: uint32(line - 1), Character: uint32(col - 1)},
+				End:   protocol.Position{Line: uint32(line - 1), Character: uint32(col)},
+			},
+			Severity: severity,
+			Source:   "nix-instantiate",
+			Message:  pending,
+		})
+		pending = ""
+	}
+
+	for _, raw := range strings.Split(stderr, "\n") {
+		line := strings.TrimSpace(raw)
+		if m := errorRe.FindStringSubmatch(line); m != nil {
+			pending = strings.TrimSpace(m[1])
+			// The older single-line format packs the location onto the
+			// same line as the message; check it before moving on.
+			if loc := locationRe.FindStringSubmatch(line); loc != nil {
+				l, _ := strconv.Atoi(loc[1])
+				c, _ := strconv.Atoi(loc[2])
+				flush(l, c)
+			}
+			continue
+		}
+		if loc := locationRe.FindStringSubmatch(line); loc != nil {
+			l, _ := strconv.Atoi(loc[1])
+			c, _ := strconv.Atoi(loc[2])
+			flush(l, c)
+		}
+	}
+	return diags
+}
@@ -0,0 +1,47 @@
+package diagnostics
+
+import (
+	"testing"
+
+	"go.lsp.dev/protocol"
+)
+
+func TestParseErrorsBoxedFormat(t *testing.T) {
+	stderr := `error:
+       error: undefined variable 'foo'
+
+       at /tmp/t.nix:3:5:
+
+            2|   bar = 1;
+            3|   baz = foo;
+             |         ^
+`
+	diags := parseErrors(stderr, protocol.DiagnosticSeverityError)
+	if len(diags) != 1 {
+		t.Fatalf("got %d diagnostics, want 1: %#v", len(diags), diags)
+	}
+	d := diags[0]
+	if d.Message != "undefined variable 'foo'" {
+		t.Fatalf("message = %q", d.Message)
+	}
+	if d.Range.Start.Line != 2 || d.Range.Start.Character != 4 {
+		t.Fatalf("range = %#v, want line 2 character 4", d.Range)
+	}
+}
+
+func TestParseErrorsSingleLineFormat(t *testing.T) {
+	stderr := "error: syntax error, unexpected ';', expecting '}' at /tmp/t.nix:1:10\n"
+	diags := parseErrors(stderr, protocol.DiagnosticSeverityError)
+	if len(diags) != 1 {
+		t.Fatalf("got %d diagnostics, want 1: %#v", len(diags), diags)
+	}
+	if diags[0].Range.Start.Line != 0 || diags[0].Range.Start.Character != 9 {
+		t.Fatalf("range = %#v, want line 0 character 9", diags[0].Range)
+	}
+}
+
+func TestParseErrorsNoErrors(t *testing.T) {
+	if diags := parseErrors("", protocol.DiagnosticSeverityError); diags != nil {
+		t.Fatalf("got %#v, want nil", diags)
+	}
+}
@@ -0,0 +1,101 @@
+package diagnostics
+
+import (
+	"bytes"
+	"os/exec"
+	"sync"
+	"time"
+
+	"go.lsp.dev/protocol"
+)
+
+// numWorkers bounds how many nix-instantiate processes can run at once, so
+// a burst of saves across many files doesn't fork unbounded subprocesses.
+const numWorkers = 4
+
+// Worker runs nix-instantiate checks off the request-handling goroutine,
+// mirroring the "diagnose the snapshot on every change" model gopls uses:
+// edits schedule a debounced background pass rather than blocking the
+// handler that applied them.
+type Worker struct {
+	cfg Config
+	pub Publisher
+
+	jobs chan job
+
+	mu     sync.Mutex
+	timers map[protocol.URI]*time.Timer
+}
+
+type job struct {
+	uri  protocol.URI
+	path string
+	eval bool
+}
+
+// NewWorker starts the Worker's fixed-size pool and returns immediately;
+// the pool runs until the process exits.
+func NewWorker(cfg Config, pub Publisher) *Worker {
+	w := &Worker{
+		cfg:    cfg,
+		pub:    pub,
+		jobs:   make(chan job, 64),
+		timers: make(map[protocol.URI]*time.Timer),
+	}
+	for i := 0; i < numWorkers; i++ {
+		go w.run()
+	}
+	return w
+}
+
+func (w *Worker) run() {
+	for j := range w.jobs {
+		w.pub.PublishDiagnostics(j.uri, w.diagnose(j))
+	}
+}
+
+// OnChange schedules a parse-only diagnostics pass for uri after the
+// configured debounce, canceling any pass already pending for it.
+func (w *Worker) OnChange(uri protocol.URI, path string) {
+	w.schedule(uri, path, false, w.cfg.Debounce)
+}
+
+// OnSave runs immediately and, if EvalOnSave is set, also evaluates the
+// file with `nix-instantiate --eval --strict` once the parse passes
+// cleanly, surfacing evaluator errors like undefined variables.
+func (w *Worker) OnSave(uri protocol.URI, path string) {
+	w.schedule(uri, path, w.cfg.EvalOnSave, 0)
+}
+
+func (w *Worker) schedule(uri protocol.URI, path string, eval bool, delay time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if t, ok := w.timers[uri]; ok {
+		t.Stop()
+	}
+	w.timers[uri] = time.AfterFunc(delay, func() {
+		w.jobs <- job{uri: uri, path: path, eval: eval}
+	})
+}
+
+func (w *Worker) diagnose(j job) []protocol.Diagnostic {
+	diags := parseErrors(w.runNixInstantiate("--parse", j.path), w.cfg.ParseSeverity)
+	if j.eval && len(diags) == 0 {
+		diags = append(diags, parseErrors(w.runNixInstantiate("--eval", "--strict", j.path), w.cfg.EvalSeverity)...)
+	}
+	return diags
+}
+
+// runNixInstantiate runs nix-instantiate and returns its stderr: nix always
+// reports parse and eval errors there, regardless of exit status.
+func (w *Worker) runNixInstantiate(args ...string) string {
+	bin := w.cfg.NixInstantiate
+	if bin == "" {
+		bin = "nix-instantiate"
+	}
+	cmd := exec.Command(bin, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	cmd.Run() // a non-zero exit just means nix-instantiate found an error to report
+	return stderr.String()
+}
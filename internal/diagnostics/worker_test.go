@@ -0,0 +1,86 @@
+package diagnostics
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.lsp.dev/protocol"
+)
+
+// fakePublisher records PublishDiagnostics calls on a channel so tests can
+// wait for the Worker's background pass without polling.
+type fakePublisher struct {
+	calls chan []protocol.Diagnostic
+}
+
+func newFakePublisher() *fakePublisher {
+	return &fakePublisher{calls: make(chan []protocol.Diagnostic, 8)}
+}
+
+func (p *fakePublisher) PublishDiagnostics(_ protocol.URI, diags []protocol.Diagnostic) {
+	p.calls <- diags
+}
+
+// fakeNixInstantiate writes a stub nix-instantiate that ignores its
+// arguments and always reports one syntax error, so the worker pipeline can
+// be exercised without the real binary (absent from this sandbox).
+func fakeNixInstantiate(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "nix-instantiate")
+	script := "#!/bin/sh\necho \"error: undefined variable 'foo' at FILE:2:3\" 1>&2\nexit 1\n"
+	if err := os.WriteFile(path, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestWorkerOnChangePublishesParseErrors(t *testing.T) {
+	pub := newFakePublisher()
+	cfg := DefaultConfig()
+	cfg.NixInstantiate = fakeNixInstantiate(t)
+	cfg.Debounce = 10 * time.Millisecond
+	w := NewWorker(cfg, pub)
+
+	u := protocol.URI("file:///t.nix")
+	w.OnChange(u, "/t.nix")
+	// Rapid re-edits should collapse into a single debounced pass.
+	w.OnChange(u, "/t.nix")
+	w.OnChange(u, "/t.nix")
+
+	select {
+	case diags := <-pub.calls:
+		if len(diags) != 1 {
+			t.Fatalf("got %d diagnostics, want 1", len(diags))
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for diagnostics")
+	}
+
+	select {
+	case extra := <-pub.calls:
+		t.Fatalf("unexpected second publish: %#v", extra)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestWorkerOnSaveRunsImmediately(t *testing.T) {
+	pub := newFakePublisher()
+	cfg := DefaultConfig()
+	cfg.NixInstantiate = fakeNixInstantiate(t)
+	w := NewWorker(cfg, pub)
+
+	u := protocol.URI("file:///t.nix")
+	w.OnSave(u, "/t.nix")
+
+	select {
+	case diags := <-pub.calls:
+		if len(diags) != 1 {
+			t.Fatalf("got %d diagnostics, want 1", len(diags))
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for diagnostics")
+	}
+}
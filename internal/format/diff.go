@@ -0,0 +1,160 @@
+package format
+
+import "strings"
+
+// op is one step of a line-level edit script.
+type op byte
+
+const (
+	opEqual op = iota
+	opDelete
+	opInsert
+)
+
+// splitLinesKeepEnds splits text into lines, each retaining its trailing
+// "\n" (the final line won't have one unless text itself ends with "\n"),
+// so that concatenating any prefix of the result reconstructs an exact
+// prefix of text.
+func splitLinesKeepEnds(text string) []string {
+	var lines []string
+	start := 0
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\n' {
+			lines = append(lines, text[start:i+1])
+			start = i + 1
+		}
+	}
+	lines = append(lines, text[start:])
+	return lines
+}
+
+// diffOps computes the minimal edit script turning a into b using Myers'
+// O(ND) diff algorithm: a forward pass builds, for each edit distance d, the
+// furthest-reaching x on every diagonal k; a backward pass over those
+// snapshots recovers the shortest path, which is then replayed in forward
+// order as one opEqual/opDelete/opInsert per line consumed from a and/or b.
+func diffOps(a, b []string) []op {
+	n, m := len(a), len(b)
+	if n == 0 && m == 0 {
+		return nil
+	}
+
+	trace := shortestEdit(a, b)
+	return backtrack(a, b, trace)
+}
+
+func shortestEdit(a, b []string) [][]int {
+	n, m := len(a), len(b)
+	max := n + m
+	v := make([]int, 2*max+1)
+	idx := func(k int) int { return k + max }
+
+	var trace [][]int
+	for d := 0; d <= max; d++ {
+		snapshot := make([]int, len(v))
+		copy(snapshot, v)
+		trace = append(trace, snapshot)
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[idx(k-1)] < v[idx(k+1)]) {
+				x = v[idx(k+1)]
+			} else {
+				x = v[idx(k-1)] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[idx(k)] = x
+			if x >= n && y >= m {
+				return trace
+			}
+		}
+	}
+	return trace
+}
+
+func backtrack(a, b []string, trace [][]int) []op {
+	n, m := len(a), len(b)
+	max := n + m
+	idx := func(k int) int { return k + max }
+
+	x, y := n, m
+	var ops []op
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && v[idx(k-1)] < v[idx(k+1)]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[idx(prevK)]
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			ops = append(ops, opEqual)
+			x--
+			y--
+		}
+		if d > 0 {
+			if x == prevX {
+				ops = append(ops, opInsert)
+			} else {
+				ops = append(ops, opDelete)
+			}
+		}
+		x, y = prevX, prevY
+	}
+
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+	return ops
+}
+
+// lineRange describes a contiguous run of lines in aLines that differs from
+// the corresponding run in bLines.
+type lineRange struct {
+	aStart, aEnd int
+	bStart, bEnd int
+}
+
+// diffHunks groups the edit script from diffOps into maximal runs of
+// consecutive non-equal operations, each becoming one replacement.
+func diffHunks(aLines, bLines []string) []lineRange {
+	ops := diffOps(aLines, bLines)
+
+	var hunks []lineRange
+	aPos, bPos := 0, 0
+	for i := 0; i < len(ops); {
+		if ops[i] == opEqual {
+			aPos++
+			bPos++
+			i++
+			continue
+		}
+		h := lineRange{aStart: aPos, bStart: bPos}
+		for i < len(ops) && ops[i] != opEqual {
+			if ops[i] == opDelete {
+				aPos++
+			} else {
+				bPos++
+			}
+			i++
+		}
+		h.aEnd, h.bEnd = aPos, bPos
+		hunks = append(hunks, h)
+	}
+	return hunks
+}
+
+// joinLines concatenates lines[start:end], each of which already carries
+// its own trailing newline (except possibly the very last line in the
+// slice).
+func joinLines(lines []string, start, end int) string {
+	return strings.Join(lines[start:end], "")
+}
@@ -0,0 +1,95 @@
+package format
+
+import "testing"
+
+func TestDiffEditsNoChange(t *testing.T) {
+	edits := diffEdits("a = 1;\nb = 2;\n", "a = 1;\nb = 2;\n")
+	if len(edits) != 0 {
+		t.Fatalf("got %d edits, want 0: %#v", len(edits), edits)
+	}
+}
+
+func TestDiffEditsSingleLineChange(t *testing.T) {
+	edits := diffEdits("a=1;\nb=2;\nc=3;\n", "a=1;\nb = 2;\nc=3;\n")
+	if len(edits) != 1 {
+		t.Fatalf("got %d edits, want 1: %#v", len(edits), edits)
+	}
+	e := edits[0]
+	if e.Range.Start.Line != 1 || e.Range.End.Line != 2 {
+		t.Fatalf("range = %+v, want lines [1,2)", e.Range)
+	}
+	if e.NewText != "b = 2;\n" {
+		t.Fatalf("newText = %q", e.NewText)
+	}
+}
+
+func TestDiffEditsInsertion(t *testing.T) {
+	edits := diffEdits("a=1;\nc=3;\n", "a=1;\nb=2;\nc=3;\n")
+	if len(edits) != 1 {
+		t.Fatalf("got %d edits, want 1: %#v", len(edits), edits)
+	}
+	e := edits[0]
+	if e.Range.Start != e.Range.End {
+		t.Fatalf("insertion range should be empty, got %+v", e.Range)
+	}
+	if e.Range.Start.Line != 1 {
+		t.Fatalf("insertion line = %d, want 1", e.Range.Start.Line)
+	}
+	if e.NewText != "b=2;\n" {
+		t.Fatalf("newText = %q", e.NewText)
+	}
+}
+
+func TestDiffEditsDeletion(t *testing.T) {
+	edits := diffEdits("a=1;\nb=2;\nc=3;\n", "a=1;\nc=3;\n")
+	if len(edits) != 1 {
+		t.Fatalf("got %d edits, want 1: %#v", len(edits), edits)
+	}
+	e := edits[0]
+	if e.NewText != "" {
+		t.Fatalf("newText = %q, want empty", e.NewText)
+	}
+	if e.Range.Start.Line != 1 || e.Range.End.Line != 2 {
+		t.Fatalf("range = %+v, want lines [1,2)", e.Range)
+	}
+}
+
+func TestDiffEditsNoTrailingNewline(t *testing.T) {
+	edits := diffEdits("a=1;\nb=2;", "a=1;\nb = 2;")
+	if len(edits) != 1 {
+		t.Fatalf("got %d edits, want 1: %#v", len(edits), edits)
+	}
+	e := edits[0]
+	if e.Range.Start.Line != 1 || e.Range.Start.Character != 0 {
+		t.Fatalf("start = %+v, want line 1 char 0", e.Range.Start)
+	}
+	if e.Range.End.Line != 1 || e.Range.End.Character != 4 {
+		t.Fatalf("end = %+v, want line 1 char 4 (end of \"b=2;\")", e.Range.End)
+	}
+	if e.NewText != "b = 2;" {
+		t.Fatalf("newText = %q", e.NewText)
+	}
+}
+
+func TestLineBoundaryEndOfTextUsesUTF16Column(t *testing.T) {
+	lines := splitLinesKeepEnds("a=1;\nb=\"😀\"")
+	pos := lineBoundary(lines, len(lines))
+	if pos.Character != uint32(len("b=\"😀\"")-2) {
+		t.Fatalf("character = %d, want %d (UTF-16 units, not %d UTF-8 bytes)", pos.Character, len("b=\"😀\"")-2, len("b=\"😀\""))
+	}
+}
+
+func TestDiffEditsFinalMultiByteLineUsesUTF16Column(t *testing.T) {
+	// Changing the un-terminated final line itself exercises the same
+	// UTF-16 conversion through the full diffEdits path, not just
+	// lineBoundary in isolation.
+	edits := diffEdits("a=1;\nb=\"😀\"", "a=1;\nb = \"😀\"")
+	if len(edits) != 1 {
+		t.Fatalf("got %d edits, want 1: %#v", len(edits), edits)
+	}
+	e := edits[0]
+	wantChar := uint32(len("b=\"😀\"") - 2) // UTF-16 units, not UTF-8 bytes
+	if e.Range.End.Line != 1 || e.Range.End.Character != wantChar {
+		t.Fatalf("end = %+v, want line 1 char %d", e.Range.End, wantChar)
+	}
+}
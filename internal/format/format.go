@@ -0,0 +1,117 @@
+// Package format runs an external Nix formatter over a document's text and
+// turns its output into a minimal set of LSP TextEdits, via a line-based
+// Myers diff, so the client applies the smallest possible edit rather than
+// replacing the whole buffer.
+package format
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"go.lsp.dev/protocol"
+
+	"github.com/legonois/nixos-lsp/internal/analysis"
+)
+
+// Config controls which formatter binary Format shells out to.
+type Config struct {
+	// Formatter is the binary name or path; defaults to "nixpkgs-fmt".
+	// "alejandra" and "nixfmt" are common alternatives.
+	Formatter string
+	// Args are extra CLI arguments passed to Formatter, after any it
+	// needs to read from stdin and write to stdout (both "nixpkgs-fmt"
+	// and "alejandra" do this with no arguments at all).
+	Args []string
+}
+
+// DefaultConfig returns the nixpkgs-fmt-backed configuration used when no
+// InitializationOptions override is given.
+func DefaultConfig() Config {
+	return Config{Formatter: "nixpkgs-fmt"}
+}
+
+func (c Config) bin() string {
+	if c.Formatter == "" {
+		return "nixpkgs-fmt"
+	}
+	return c.Formatter
+}
+
+// Format runs the configured formatter over text and returns the edits
+// needed to turn it into the formatted result.
+func Format(cfg Config, text string) ([]protocol.TextEdit, error) {
+	out, err := run(cfg, text)
+	if err != nil {
+		return nil, err
+	}
+	return diffEdits(text, out), nil
+}
+
+// FormatRange runs the configured formatter over the whole of text — none
+// of nixpkgs-fmt, alejandra, or nixfmt support formatting a sub-range — and
+// keeps only the edits that fall within r, so a range-formatting request
+// still narrows its effect to roughly what was asked for.
+func FormatRange(cfg Config, text string, r protocol.Range) ([]protocol.TextEdit, error) {
+	edits, err := Format(cfg, text)
+	if err != nil {
+		return nil, err
+	}
+	var out []protocol.TextEdit
+	for _, e := range edits {
+		if e.Range.Start.Line >= r.Start.Line && e.Range.End.Line <= r.End.Line {
+			out = append(out, e)
+		}
+	}
+	return out, nil
+}
+
+func run(cfg Config, text string) (string, error) {
+	cmd := exec.Command(cfg.bin(), cfg.Args...)
+	cmd.Stdin = strings.NewReader(text)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if stderr.Len() > 0 {
+			return "", fmt.Errorf("%s: %w: %s", cfg.bin(), err, strings.TrimSpace(stderr.String()))
+		}
+		return "", fmt.Errorf("%s: %w", cfg.bin(), err)
+	}
+	return stdout.String(), nil
+}
+
+// diffEdits computes the minimal []protocol.TextEdit that turns oldText
+// into newText, by diffing them line by line and reporting one edit per
+// contiguous run of changed lines.
+func diffEdits(oldText, newText string) []protocol.TextEdit {
+	aLines := splitLinesKeepEnds(oldText)
+	bLines := splitLinesKeepEnds(newText)
+
+	var edits []protocol.TextEdit
+	for _, h := range diffHunks(aLines, bLines) {
+		edits = append(edits, protocol.TextEdit{
+			Range: protocol.Range{
+				Start: lineBoundary(aLines, h.aStart),
+				End:   lineBoundary(aLines, h.aEnd),
+			},
+			NewText: joinLines(bLines, h.bStart, h.bEnd),
+		})
+	}
+	return edits
+}
+
+// lineBoundary returns the LSP position at the start of lines[idx] (column
+// 0), or, for idx == len(lines), the position at the very end of the text —
+// the end of the final line, which (unlike every other line) carries no
+// trailing newline to be the start of a next one. That end-of-text column
+// is counted in UTF-16 code units, per the LSP spec, not the bytes len()
+// counts.
+func lineBoundary(lines []string, idx int) protocol.Position {
+	if idx < len(lines) {
+		return protocol.Position{Line: uint32(idx), Character: 0}
+	}
+	last := lines[len(lines)-1]
+	return protocol.Position{Line: uint32(len(lines) - 1), Character: uint32(analysis.UTF16Units(last))}
+}
@@ -0,0 +1,71 @@
+package format
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.lsp.dev/protocol"
+)
+
+// fakeFormatter writes an executable shell script that copies stdin to
+// stdout via sed, so Format can be exercised without the real
+// nixpkgs-fmt/alejandra/nixfmt binaries (absent from this sandbox).
+func fakeFormatter(t *testing.T, sedExpr string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "fake-fmt")
+	script := "#!/bin/sh\nsed '" + sedExpr + "'\n"
+	if err := os.WriteFile(path, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestFormatReturnsEditsFromFormatterOutput(t *testing.T) {
+	bin := fakeFormatter(t, "s/=/ = /")
+	cfg := Config{Formatter: bin}
+
+	// The unchanged middle line gives the diff an anchor, so the two
+	// reformatted lines surface as two separate, non-adjacent edits
+	// instead of being merged into one larger replacement.
+	edits, err := Format(cfg, "a=1;\nunchanged;\nb=2;\n")
+	if err != nil {
+		t.Fatalf("Format: %v", err)
+	}
+	if len(edits) != 2 {
+		t.Fatalf("got %d edits, want 2: %#v", len(edits), edits)
+	}
+}
+
+func TestFormatRangeKeepsOnlyOverlappingEdits(t *testing.T) {
+	bin := fakeFormatter(t, "s/=/ = /")
+	cfg := Config{Formatter: bin}
+
+	r := protocol.Range{
+		Start: protocol.Position{Line: 0, Character: 0},
+		End:   protocol.Position{Line: 1, Character: 0},
+	}
+	edits, err := FormatRange(cfg, "a=1;\nunchanged;\nb=2;\n", r)
+	if err != nil {
+		t.Fatalf("FormatRange: %v", err)
+	}
+	if len(edits) != 1 {
+		t.Fatalf("got %d edits, want 1: %#v", len(edits), edits)
+	}
+	if edits[0].Range.Start.Line != 0 {
+		t.Fatalf("edit line = %d, want 0", edits[0].Range.Start.Line)
+	}
+}
+
+func TestFormatSurfacesStderrOnFailure(t *testing.T) {
+	bin := filepath.Join(t.TempDir(), "fail-fmt")
+	script := "#!/bin/sh\necho 'syntax error' >&2\nexit 1\n"
+	if err := os.WriteFile(bin, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := Format(Config{Formatter: bin}, "a=1;\n")
+	if err == nil {
+		t.Fatal("expected an error from a failing formatter")
+	}
+}
@@ -0,0 +1,116 @@
+// Package hover answers textDocument/hover requests: the definition site
+// and inferred kind (function, attrset, derivation, primitive) of the
+// identifier or attribute-path segment under the cursor, plus — for a
+// `pkgs.<path>` segment — that package's nixpkgs meta (description,
+// homepage, license, platforms), fetched through a cached nix-instantiate
+// evaluation.
+package hover
+
+import (
+	"go.lsp.dev/protocol"
+
+	"github.com/legonois/nixos-lsp/internal/analysis"
+	"github.com/legonois/nixos-lsp/internal/nix"
+	"github.com/legonois/nixos-lsp/internal/nixpkgs"
+)
+
+// Provider answers hover requests using a Snapshot's scope analysis and a
+// nixpkgs.MetaCache for pkgs.* attribute documentation.
+type Provider struct {
+	meta *nixpkgs.MetaCache
+}
+
+// New creates a Provider backed by meta. A nil meta disables pkgs.*
+// documentation lookups, falling back to the scope-based hover for every
+// attribute path.
+func New(meta *nixpkgs.MetaCache) *Provider {
+	return &Provider{meta: meta}
+}
+
+// Hover answers a hover request at pos in doc, or nil if pos isn't over an
+// identifier or attribute-path segment hover has something to say about.
+func (p *Provider) Hover(snap *analysis.Snapshot, doc *analysis.Document, pos nix.Position) *protocol.Hover {
+	path := nix.FindPath(doc.File.Root, pos)
+	if len(path) == 0 {
+		return nil
+	}
+	switch n := path[len(path)-1].(type) {
+	case *nix.Ident:
+		return p.hoverForIdent(snap, doc, n)
+	case *nix.Select:
+		return p.hoverForSelect(snap, doc, n, pos)
+	}
+	return nil
+}
+
+func (p *Provider) hoverForIdent(snap *analysis.Snapshot, doc *analysis.Document, id *nix.Ident) *protocol.Hover {
+	r, ok := snap.ResolveIdent(doc, id)
+	if !ok {
+		return nil
+	}
+	return &protocol.Hover{
+		Contents: protocol.MarkupContent{Kind: protocol.Markdown, Value: renderDefinition(id.Name, r)},
+		Range:    rangePtr(doc.Text, id.Span()),
+	}
+}
+
+// hoverForSelect hovers the path segment at pos inside sel. When that
+// segment is part of a `pkgs.<path>` chain rooted directly at the `pkgs`
+// identifier, it prefers nixpkgs meta over the (usually unresolvable, since
+// `pkgs` is ordinarily a function parameter) scope-based lookup.
+func (p *Provider) hoverForSelect(snap *analysis.Snapshot, doc *analysis.Document, sel *nix.Select, pos nix.Position) *protocol.Hover {
+	idx := segmentAt(sel, pos)
+	if idx < 0 {
+		return nil
+	}
+
+	if p.meta != nil {
+		if root, ok := sel.Expr.(*nix.Ident); ok && root.Name == "pkgs" {
+			if segs, ok := attrPathSegments(sel, idx); ok {
+				if m, err := p.meta.Meta(segs); err == nil {
+					return &protocol.Hover{
+						Contents: protocol.MarkupContent{Kind: protocol.Markdown, Value: renderMeta(segs, m)},
+						Range:    rangePtr(doc.Text, sel.Path[idx].Span),
+					}
+				}
+			}
+		}
+	}
+
+	r, ok := snap.ResolveSelect(doc, sel, pos)
+	if !ok {
+		return nil
+	}
+	return &protocol.Hover{
+		Contents: protocol.MarkupContent{Kind: protocol.Markdown, Value: renderDefinition(sel.Path[idx].Name, r)},
+		Range:    rangePtr(doc.Text, sel.Path[idx].Span),
+	}
+}
+
+func segmentAt(sel *nix.Select, pos nix.Position) int {
+	for i, seg := range sel.Path {
+		if seg.Span.Contains(pos) {
+			return i
+		}
+	}
+	return -1
+}
+
+// attrPathSegments collects sel's segment names from its root through index
+// upto (inclusive), failing if any of them is a `${...}` dynamic segment —
+// nixpkgs meta can only be evaluated for a statically-known attribute path.
+func attrPathSegments(sel *nix.Select, upto int) ([]string, bool) {
+	segs := make([]string, 0, upto+1)
+	for i := 0; i <= upto; i++ {
+		if sel.Path[i].Name == "" {
+			return nil, false
+		}
+		segs = append(segs, sel.Path[i].Name)
+	}
+	return segs, true
+}
+
+func rangePtr(text string, sp nix.Span) *protocol.Range {
+	r := analysis.SpanToRange(text, sp)
+	return &r
+}
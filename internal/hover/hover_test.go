@@ -0,0 +1,140 @@
+package hover
+
+import (
+	"strings"
+	"testing"
+
+	"go.lsp.dev/protocol"
+
+	"github.com/legonois/nixos-lsp/internal/analysis"
+	"github.com/legonois/nixos-lsp/internal/nix"
+	"github.com/legonois/nixos-lsp/internal/nixpkgs"
+)
+
+func open(t *testing.T, src string) (*analysis.Snapshot, *analysis.Document) {
+	t.Helper()
+	sess := analysis.NewSession()
+	u := protocol.URI("file:///t.nix")
+	snap := sess.Open(u, src, 1)
+	doc, _ := snap.Get(u)
+	return snap, doc
+}
+
+func value(t *testing.T, h *protocol.Hover) string {
+	t.Helper()
+	if h == nil {
+		t.Fatal("Hover() = nil")
+	}
+	return h.Contents.Value
+}
+
+func TestHoverLetBindingShowsValueAndKind(t *testing.T) {
+	src := `let greeting = "hi"; in greeting`
+	snap, doc := open(t, src)
+	p := New(nil)
+
+	pos := nix.Position{Offset: len(`let greeting = "hi"; in `)}
+	h := p.Hover(snap, doc, pos)
+	v := value(t, h)
+	if !strings.Contains(v, `greeting = "hi"`) {
+		t.Fatalf("value = %q, want it to quote the binding", v)
+	}
+	if !strings.Contains(v, "*primitive*") {
+		t.Fatalf("value = %q, want the primitive kind", v)
+	}
+	if h.Range == nil || h.Range.Start.Character != uint32(len(`let greeting = "hi"; in `)) {
+		t.Fatalf("range = %#v, want it to span the use site's greeting token", h.Range)
+	}
+}
+
+func TestHoverFunctionBinding(t *testing.T) {
+	src := `let f = x: x + 1; in f`
+	snap, doc := open(t, src)
+	p := New(nil)
+
+	pos := nix.Position{Offset: len(`let f = x: x + 1; in `)}
+	v := value(t, p.Hover(snap, doc, pos))
+	if !strings.Contains(v, "*function*") {
+		t.Fatalf("value = %q, want the function kind", v)
+	}
+}
+
+func TestHoverMkDerivationCall(t *testing.T) {
+	src := `let pkg = stdenv.mkDerivation { name = "x"; }; in pkg`
+	snap, doc := open(t, src)
+	p := New(nil)
+
+	pos := nix.Position{Offset: len(src) - 3}
+	v := value(t, p.Hover(snap, doc, pos))
+	if !strings.Contains(v, "*derivation*") {
+		t.Fatalf("value = %q, want the derivation kind", v)
+	}
+}
+
+func TestHoverAttrPathSelect(t *testing.T) {
+	src := `let set = { hello = 1; }; in set.hello`
+	snap, doc := open(t, src)
+	p := New(nil)
+
+	pos := nix.Position{Offset: len(src) - 2}
+	v := value(t, p.Hover(snap, doc, pos))
+	if !strings.Contains(v, "hello = 1") {
+		t.Fatalf("value = %q, want it to quote hello's binding", v)
+	}
+	if !strings.Contains(v, "*primitive*") {
+		t.Fatalf("value = %q, want the primitive kind", v)
+	}
+}
+
+func TestHoverPkgsAttrPathUsesNixpkgsMeta(t *testing.T) {
+	meta := nixpkgs.NewStaticMetaCache(map[string]*nixpkgs.Meta{
+		"hello": {
+			Description: "A friendly program",
+			Homepage:    "https://example.org",
+			License:     "mit",
+			Platforms:   []string{"x86_64-linux"},
+		},
+	})
+	src := `pkgs.hello`
+	snap, doc := open(t, src)
+	p := New(meta)
+
+	pos := nix.Position{Offset: len(src) - 2}
+	h := p.Hover(snap, doc, pos)
+	v := value(t, h)
+	if !strings.Contains(v, "A friendly program") {
+		t.Fatalf("value = %q, want the package description", v)
+	}
+	if !strings.Contains(v, "https://example.org") {
+		t.Fatalf("value = %q, want the homepage", v)
+	}
+	if !strings.Contains(v, "x86_64-linux") {
+		t.Fatalf("value = %q, want the platform", v)
+	}
+	wantStart := uint32(len("pkgs."))
+	if h.Range == nil || h.Range.Start.Character != wantStart {
+		t.Fatalf("range = %#v, want it to span the hello segment", h.Range)
+	}
+}
+
+func TestHoverNoMetaCacheFallsBackToScope(t *testing.T) {
+	src := `let pkgs = { hello = 1; }; in pkgs.hello`
+	snap, doc := open(t, src)
+	p := New(nil)
+
+	pos := nix.Position{Offset: len(src) - 2}
+	v := value(t, p.Hover(snap, doc, pos))
+	if !strings.Contains(v, "hello = 1") {
+		t.Fatalf("value = %q, want the local pkgs.hello binding", v)
+	}
+}
+
+func TestHoverUnresolvableReturnsNil(t *testing.T) {
+	src := `1 + 1`
+	snap, doc := open(t, src)
+	p := New(nil)
+
+	if h := p.Hover(snap, doc, nix.Position{Offset: 0}); h != nil {
+		t.Fatalf("Hover() = %#v, want nil for a literal", h)
+	}
+}
@@ -0,0 +1,140 @@
+package hover
+
+import (
+	"strings"
+
+	"github.com/legonois/nixos-lsp/internal/analysis"
+	"github.com/legonois/nixos-lsp/internal/nix"
+	"github.com/legonois/nixos-lsp/internal/nixpkgs"
+)
+
+// maxSnippetLen bounds how much of a definition's source renderDefinition
+// quotes back, so hovering a huge attrset or function body doesn't dump the
+// whole thing into a tooltip.
+const maxSnippetLen = 120
+
+// renderDefinition formats a Resolved binding as Markdown: a one-line code
+// block showing `name = <snippet>` and, when the bound expression is
+// statically known, its inferred kind.
+func renderDefinition(name string, r analysis.Resolved) string {
+	var b strings.Builder
+	b.WriteString("```nix\n")
+	b.WriteString(name)
+	if r.Value != nil {
+		b.WriteString(" = ")
+		b.WriteString(snippet(r.Doc.Text, r.Value.Span()))
+	}
+	b.WriteString("\n```")
+	if kind := classify(r.Value); kind != "" {
+		b.WriteString("\n\n*" + kind + "*")
+	}
+	return b.String()
+}
+
+// renderMeta formats a pkgs.<path> package's nixpkgs meta as Markdown.
+func renderMeta(path []string, m *nixpkgs.Meta) string {
+	var b strings.Builder
+	b.WriteString("```nix\npkgs.")
+	b.WriteString(strings.Join(path, "."))
+	b.WriteString("\n```")
+
+	if m.Description != "" {
+		b.WriteString("\n\n")
+		b.WriteString(m.Description)
+	}
+
+	var fields []string
+	if m.Homepage != "" {
+		fields = append(fields, "**Homepage:** "+m.Homepage)
+	}
+	if m.License != "" {
+		fields = append(fields, "**License:** "+m.License)
+	}
+	if len(m.Platforms) > 0 {
+		fields = append(fields, "**Platforms:** "+strings.Join(m.Platforms, ", "))
+	}
+	if len(fields) > 0 {
+		b.WriteString("\n\n")
+		b.WriteString(strings.Join(fields, "  \n"))
+	}
+	return b.String()
+}
+
+// snippet extracts sp's source text from text, collapsing it to a single
+// line and truncating it to maxSnippetLen — a Hover tooltip quotes a
+// definition, it doesn't reproduce it.
+func snippet(text string, sp nix.Span) string {
+	if sp.Start.Offset < 0 || sp.End.Offset > len(text) || sp.Start.Offset > sp.End.Offset {
+		return ""
+	}
+	s := text[sp.Start.Offset:sp.End.Offset]
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		s = s[:i] + " …"
+	}
+	if len(s) > maxSnippetLen {
+		s = s[:maxSnippetLen] + " …"
+	}
+	return s
+}
+
+// classify infers a bound expression's kind for Hover's "inferred kind"
+// line. It recognizes the four shapes the spec calls out by name —
+// function, attrset, derivation, primitive — falling back to "expression"
+// for anything else statically known (a select, a binary op, an
+// unrecognized function call, ...).
+func classify(expr nix.Expr) string {
+	switch e := expr.(type) {
+	case nil:
+		return ""
+	case *nix.Function:
+		return "function"
+	case *nix.AttrSet:
+		if isDerivation(e) {
+			return "derivation"
+		}
+		return "attrset"
+	case *nix.Apply:
+		if isMkDerivationCall(e) {
+			return "derivation"
+		}
+		return "expression"
+	case *nix.Int, *nix.Float, *nix.Bool, *nix.Null, *nix.Str, *nix.PathLit, *nix.SearchPath, *nix.URILit, *nix.ListExpr:
+		return "primitive"
+	default:
+		return "expression"
+	}
+}
+
+// isDerivation reports whether set looks like an already-evaluated
+// derivation's attrset by the convention every nix.Derivation satisfies: a
+// literal `type = "derivation";` binding.
+func isDerivation(set *nix.AttrSet) bool {
+	for _, b := range set.Bindings {
+		ab, ok := b.(*nix.AttrBinding)
+		if !ok || len(ab.Path) != 1 || ab.Path[0].Name != "type" {
+			continue
+		}
+		str, ok := ab.Value.(*nix.Str)
+		if ok && len(str.Parts) == 1 && str.Parts[0].Literal == "derivation" {
+			return true
+		}
+	}
+	return false
+}
+
+// isMkDerivationCall reports whether apply looks like `stdenv.mkDerivation
+// { ... }` or `pkgs.mkDerivation { ... }` — the call itself isn't
+// statically evaluable, but the name of what's being called is a reliable
+// enough signal without running the evaluator.
+func isMkDerivationCall(apply *nix.Apply) bool {
+	switch fn := apply.Fn.(type) {
+	case *nix.Ident:
+		return strings.Contains(fn.Name, "mkDerivation")
+	case *nix.Select:
+		if len(fn.Path) == 0 {
+			return false
+		}
+		return strings.Contains(fn.Path[len(fn.Path)-1].Name, "mkDerivation")
+	}
+	return false
+}
@@ -0,0 +1,293 @@
+package nix
+
+// Span is the source range covered by a Node.
+type Span struct {
+	Start Position
+	End   Position
+}
+
+// Node is implemented by every AST node.
+type Node interface {
+	Span() Span
+}
+
+// Expr is implemented by every expression node.
+type Expr interface {
+	Node
+	exprNode()
+}
+
+type baseNode struct{ span Span }
+
+func (n baseNode) Span() Span { return n.span }
+
+// Ident is a bare identifier reference, e.g. `pkgs` or `x`.
+type Ident struct {
+	baseNode
+	Name string
+}
+
+// Int is an integer literal.
+type Int struct {
+	baseNode
+	Value int64
+}
+
+// Float is a floating point literal.
+type Float struct {
+	baseNode
+	Value float64
+}
+
+// Bool is `true` or `false`.
+type Bool struct {
+	baseNode
+	Value bool
+}
+
+// Null is the `null` literal.
+type Null struct{ baseNode }
+
+// PathLit is a path literal such as `./foo.nix` or `~/bar`.
+type PathLit struct {
+	baseNode
+	Value string
+}
+
+// SearchPath is a `<nixpkgs>`-style lookup-path literal.
+type SearchPath struct {
+	baseNode
+	Value string
+}
+
+// URILit is a bare URI literal such as `https://example.com/x.tar.gz`.
+type URILit struct {
+	baseNode
+	Value string
+}
+
+// StringPart is one piece of a (possibly interpolated) string literal.
+type StringPart struct {
+	// Literal holds the raw text for a non-interpolated part.
+	Literal string
+	// Interp holds the expression for a `${...}` part; nil for Literal parts.
+	Interp Expr
+}
+
+// Str is a string literal, potentially containing `${...}` interpolations.
+type Str struct {
+	baseNode
+	Parts []StringPart
+}
+
+// ListExpr is a `[ ... ]` list literal.
+type ListExpr struct {
+	baseNode
+	Elems []Expr
+}
+
+// AttrPathSegment is one component of a dotted attribute path. Static
+// segments (the common case) carry Name; dynamic segments such as
+// `${expr}` carry Expr instead.
+type AttrPathSegment struct {
+	Span Span
+	Name string
+	Expr Expr // non-nil for `${...}` dynamic segments
+}
+
+// Binding is implemented by AttrBinding and InheritBinding, the two kinds of
+// statement that can appear inside an attrset or let block.
+type Binding interface {
+	Node
+	bindingNode()
+}
+
+// AttrBinding is `path.to.attr = value;`.
+type AttrBinding struct {
+	baseNode
+	Path  []AttrPathSegment
+	Value Expr
+}
+
+func (AttrBinding) bindingNode() {}
+
+// InheritBinding is `inherit a b;` or `inherit (from) a b;`.
+type InheritBinding struct {
+	baseNode
+	From  Expr // nil for a plain `inherit a b;`
+	Names []AttrPathSegment
+}
+
+func (InheritBinding) bindingNode() {}
+
+// AttrSet is `{ ... }` or `rec { ... }`.
+type AttrSet struct {
+	baseNode
+	Rec      bool
+	Bindings []Binding
+}
+
+// Names returns the static (non-dynamic) top-level attribute names bound by
+// set, in source order. A `${...}` dynamic segment's name isn't known
+// without evaluation, so it's skipped.
+func (a *AttrSet) Names() []string {
+	var out []string
+	for _, b := range a.Bindings {
+		switch bind := b.(type) {
+		case *AttrBinding:
+			if len(bind.Path) > 0 && bind.Path[0].Name != "" {
+				out = append(out, bind.Path[0].Name)
+			}
+		case *InheritBinding:
+			for _, n := range bind.Names {
+				if n.Name != "" {
+					out = append(out, n.Name)
+				}
+			}
+		}
+	}
+	return out
+}
+
+// LetIn is `let ... in body`.
+type LetIn struct {
+	baseNode
+	Bindings []Binding
+	Body     Expr
+}
+
+// With is `with expr; body`.
+type With struct {
+	baseNode
+	Expr Expr
+	Body Expr
+}
+
+// Param is implemented by IdentParam and PatternParam, the two forms a
+// function argument can take.
+type Param interface {
+	Node
+	paramNode()
+}
+
+// IdentParam is a plain `x: body` parameter.
+type IdentParam struct {
+	baseNode
+	Name string
+}
+
+func (IdentParam) paramNode() {}
+
+// PatternField is one `name` or `name ? default` entry of a PatternParam.
+type PatternField struct {
+	Span    Span
+	Name    string
+	Default Expr // nil if no default given
+}
+
+// PatternParam is a `{ a, b ? d, ... }@name: body` destructuring parameter.
+type PatternParam struct {
+	baseNode
+	Fields   []PatternField
+	Ellipsis bool
+	Bind     string // name bound by `@name`; empty if absent
+}
+
+func (PatternParam) paramNode() {}
+
+// Function is `param: body`.
+type Function struct {
+	baseNode
+	Param Param
+	Body  Expr
+}
+
+// Apply is function application `fn arg`.
+type Apply struct {
+	baseNode
+	Fn  Expr
+	Arg Expr
+}
+
+// UnaryOp is `-expr` or `!expr`.
+type UnaryOp struct {
+	baseNode
+	Op   TokenKind
+	Expr Expr
+}
+
+// BinaryOp is any infix operator expression.
+type BinaryOp struct {
+	baseNode
+	Op    TokenKind
+	Left  Expr
+	Right Expr
+}
+
+// If is `if cond then t else e`.
+type If struct {
+	baseNode
+	Cond Expr
+	Then Expr
+	Else Expr
+}
+
+// Assert is `assert cond; body`.
+type Assert struct {
+	baseNode
+	Cond Expr
+	Body Expr
+}
+
+// Select is `expr.path.to.attr` with an optional `or default`.
+type Select struct {
+	baseNode
+	Expr    Expr
+	Path    []AttrPathSegment
+	Default Expr // nil if no `or` clause
+}
+
+// HasAttr is `expr ? path.to.attr`.
+type HasAttr struct {
+	baseNode
+	Expr Expr
+	Path []AttrPathSegment
+}
+
+func (Ident) exprNode()      {}
+func (Int) exprNode()        {}
+func (Float) exprNode()      {}
+func (Bool) exprNode()       {}
+func (Null) exprNode()       {}
+func (PathLit) exprNode()    {}
+func (SearchPath) exprNode() {}
+func (URILit) exprNode()     {}
+func (Str) exprNode()        {}
+func (ListExpr) exprNode()   {}
+func (AttrSet) exprNode()    {}
+func (LetIn) exprNode()      {}
+func (With) exprNode()       {}
+func (Function) exprNode()   {}
+func (Apply) exprNode()      {}
+func (UnaryOp) exprNode()    {}
+func (BinaryOp) exprNode()   {}
+func (If) exprNode()         {}
+func (Assert) exprNode()     {}
+func (Select) exprNode()     {}
+func (HasAttr) exprNode()    {}
+
+// File is the parsed result of a whole document: its top-level expression
+// plus any syntax errors encountered while recovering.
+type File struct {
+	Root   Expr
+	Errors []SyntaxError
+}
+
+// SyntaxError describes a single parse error with its source span, so
+// callers can surface it as an LSP diagnostic.
+type SyntaxError struct {
+	Message string
+	Span    Span
+}
+
+func (e SyntaxError) Error() string { return e.Message }
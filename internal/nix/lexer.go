@@ -0,0 +1,422 @@
+package nix
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// lexFrame tracks one level of nesting the Lexer is inside: either a string
+// literal (waiting for its closing delimiter or a `${`), or an
+// interpolation (waiting for the `}` that balances its own `${`, while
+// passing unrelated `{`/`}` pairs through as ordinary brace tokens).
+type lexFrame struct {
+	inString   bool
+	delim      string // "\"" or "''", only meaningful when inString
+	braceDepth int    // only meaningful when !inString (inside `${ ... }`)
+}
+
+// Lexer turns Nix source text into a flat stream of Tokens. It tracks
+// string/interpolation nesting via a frame stack so Next can interleave
+// TokenStringPart tokens with ordinary expression tokens inside `${...}`.
+type Lexer struct {
+	src   string
+	pos   int
+	line  int
+	col   int
+	stack []lexFrame
+}
+
+// NewLexer creates a Lexer over src.
+func NewLexer(src string) *Lexer {
+	return &Lexer{src: src}
+}
+
+// Tokenize runs l to completion and returns every token, including a
+// trailing TokenEOF.
+func Tokenize(src string) []Token {
+	l := NewLexer(src)
+	var toks []Token
+	for {
+		t := l.Next()
+		toks = append(toks, t)
+		if t.Kind == TokenEOF {
+			return toks
+		}
+	}
+}
+
+func (l *Lexer) pposition() Position {
+	return Position{Line: l.line, Column: l.col, Offset: l.pos}
+}
+
+func (l *Lexer) peekByte() byte {
+	if l.pos >= len(l.src) {
+		return 0
+	}
+	return l.src[l.pos]
+}
+
+func (l *Lexer) peekByteAt(off int) byte {
+	if l.pos+off >= len(l.src) {
+		return 0
+	}
+	return l.src[l.pos+off]
+}
+
+func (l *Lexer) advance() byte {
+	b := l.src[l.pos]
+	l.pos++
+	if b == '\n' {
+		l.line++
+		l.col = 0
+	} else {
+		l.col++
+	}
+	return b
+}
+
+func (l *Lexer) startsWith(s string) bool {
+	return strings.HasPrefix(l.src[l.pos:], s)
+}
+
+func (l *Lexer) advanceN(n int) {
+	for i := 0; i < n; i++ {
+		l.advance()
+	}
+}
+
+func isIdentStart(r byte) bool {
+	return r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+}
+
+func isIdentCont(r byte) bool {
+	return isIdentStart(r) || (r >= '0' && r <= '9') || r == '\'' || r == '-'
+}
+
+func isDigit(r byte) bool { return r >= '0' && r <= '9' }
+
+// Next returns the next token in the stream, or a TokenEOF token once the
+// input is exhausted.
+func (l *Lexer) Next() Token {
+	if n := len(l.stack); n > 0 {
+		top := l.stack[n-1]
+		if top.inString {
+			return l.nextInString()
+		}
+	}
+	return l.nextNormal()
+}
+
+func (l *Lexer) nextNormal() Token {
+	l.skipTrivia()
+	start := l.pposition()
+	if l.pos >= len(l.src) {
+		return Token{Kind: TokenEOF, Start: start, End: start}
+	}
+
+	b := l.peekByte()
+
+	// Closing `}` of an interpolation frame: only the brace at that frame's
+	// own depth ends the interpolation; nested attrset braces pass through.
+	if n := len(l.stack); n > 0 && !l.stack[n-1].inString && b == '}' {
+		if l.stack[n-1].braceDepth == 0 {
+			l.advance()
+			l.stack = l.stack[:n-1]
+			return Token{Kind: TokenInterpEnd, Text: "}", Start: start, End: l.pposition()}
+		}
+		l.stack[n-1].braceDepth--
+		l.advance()
+		return Token{Kind: TokenRBrace, Text: "}", Start: start, End: l.pposition()}
+	}
+	if n := len(l.stack); n > 0 && !l.stack[n-1].inString && b == '{' {
+		l.stack[n-1].braceDepth++
+		l.advance()
+		return Token{Kind: TokenLBrace, Text: "{", Start: start, End: l.pposition()}
+	}
+
+	switch {
+	case l.startsWith("${"):
+		// A bare `${expr}` dynamic attribute name outside of a string,
+		// e.g. `a.${name} = 1;`. Reuses the same interpolation frame
+		// machinery as string interpolation.
+		l.advanceN(2)
+		l.stack = append(l.stack, lexFrame{inString: false})
+		return Token{Kind: TokenInterpStart, Text: "${", Start: start, End: l.pposition()}
+	case l.startsWith("''"):
+		l.advanceN(2)
+		l.stack = append(l.stack, lexFrame{inString: true, delim: "''"})
+		return Token{Kind: TokenStringStart, Text: "''", Start: start, End: l.pposition()}
+	case b == '"':
+		l.advance()
+		l.stack = append(l.stack, lexFrame{inString: true, delim: "\""})
+		return Token{Kind: TokenStringStart, Text: "\"", Start: start, End: l.pposition()}
+	case isIdentStart(b):
+		return l.lexIdentOrPathOrURI(start)
+	case isDigit(b):
+		return l.lexNumber(start)
+	case b == '/' && isPathChar(l.peekByteAt(1)) && l.peekByteAt(1) != 0:
+		return l.lexPath(start)
+	case b == '~':
+		return l.lexPath(start)
+	case b == '.' && (l.startsWith("./") || l.startsWith("../")):
+		return l.lexPath(start)
+	case b == '<':
+		if l.looksLikeSearchPath() {
+			return l.lexSearchPath(start)
+		}
+	}
+
+	return l.lexOperator(start)
+}
+
+func (l *Lexer) skipTrivia() {
+	for l.pos < len(l.src) {
+		b := l.peekByte()
+		switch {
+		case b == ' ' || b == '\t' || b == '\r' || b == '\n':
+			l.advance()
+		case b == '#':
+			for l.pos < len(l.src) && l.peekByte() != '\n' {
+				l.advance()
+			}
+		case l.startsWith("/*"):
+			l.advanceN(2)
+			for l.pos < len(l.src) && !l.startsWith("*/") {
+				l.advance()
+			}
+			if l.pos < len(l.src) {
+				l.advanceN(2)
+			}
+		default:
+			return
+		}
+	}
+}
+
+func isPathChar(b byte) bool {
+	return b == '.' || b == '_' || b == '-' || b == '/' || (b >= 'a' && b <= 'z') ||
+		(b >= 'A' && b <= 'Z') || (b >= '0' && b <= '9')
+}
+
+func (l *Lexer) lexIdentOrPathOrURI(start Position) Token {
+	for l.pos < len(l.src) && isIdentCont(l.peekByte()) {
+		l.advance()
+	}
+	if l.peekByte() == ':' && isURIScheme(l.src[start.Offset:l.pos]) && isURIRest(l.peekByteAt(1)) {
+		return l.lexURI(start)
+	}
+	if (l.peekByte() == '/' || l.peekByte() == '.') && l.looksLikePathContinuation(start) {
+		return l.lexPath(start)
+	}
+	text := l.src[start.Offset:l.pos]
+	kind := TokenIdent
+	if kw, ok := keywords[text]; ok {
+		kind = kw
+	}
+	return Token{Kind: kind, Text: text, Start: start, End: l.pposition()}
+}
+
+func (l *Lexer) looksLikePathContinuation(start Position) bool {
+	save := *l
+	for l.pos < len(l.src) && isPathChar(l.peekByte()) {
+		l.advance()
+	}
+	hasSlash := strings.ContainsRune(l.src[start.Offset:l.pos], '/')
+	*l = save
+	return hasSlash
+}
+
+func isURIScheme(s string) bool {
+	if len(s) == 0 || !((s[0] >= 'a' && s[0] <= 'z') || (s[0] >= 'A' && s[0] <= 'Z')) {
+		return false
+	}
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		if !(isIdentStart(c) || isDigit(c) || c == '+' || c == '-' || c == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+func isURIRest(b byte) bool { return b != 0 && b != ' ' && b != '\t' && b != '\n' }
+
+func (l *Lexer) lexURI(start Position) Token {
+	for l.pos < len(l.src) {
+		b := l.peekByte()
+		if b == ' ' || b == '\t' || b == '\n' || b == '\r' || b == ';' || b == ')' || b == '}' || b == ']' {
+			break
+		}
+		l.advance()
+	}
+	return Token{Kind: TokenURI, Text: l.src[start.Offset:l.pos], Start: start, End: l.pposition()}
+}
+
+func (l *Lexer) looksLikeSearchPath() bool {
+	i := l.pos + 1
+	for i < len(l.src) && l.src[i] != '>' && l.src[i] != '\n' {
+		if !isPathChar(l.src[i]) {
+			return false
+		}
+		i++
+	}
+	return i < len(l.src) && l.src[i] == '>'
+}
+
+func (l *Lexer) lexSearchPath(start Position) Token {
+	l.advance() // '<'
+	for l.pos < len(l.src) && l.peekByte() != '>' {
+		l.advance()
+	}
+	if l.pos < len(l.src) {
+		l.advance() // '>'
+	}
+	return Token{Kind: TokenSPath, Text: l.src[start.Offset:l.pos], Start: start, End: l.pposition()}
+}
+
+func (l *Lexer) lexPath(start Position) Token {
+	for l.pos < len(l.src) && isPathChar(l.peekByte()) {
+		l.advance()
+	}
+	return Token{Kind: TokenPath, Text: l.src[start.Offset:l.pos], Start: start, End: l.pposition()}
+}
+
+func (l *Lexer) lexNumber(start Position) Token {
+	for l.pos < len(l.src) && isDigit(l.peekByte()) {
+		l.advance()
+	}
+	kind := TokenInt
+	if l.peekByte() == '.' && isDigit(l.peekByteAt(1)) {
+		kind = TokenFloat
+		l.advance()
+		for l.pos < len(l.src) && isDigit(l.peekByte()) {
+			l.advance()
+		}
+	}
+	if l.peekByte() == 'e' || l.peekByte() == 'E' {
+		kind = TokenFloat
+		l.advance()
+		if l.peekByte() == '+' || l.peekByte() == '-' {
+			l.advance()
+		}
+		for l.pos < len(l.src) && isDigit(l.peekByte()) {
+			l.advance()
+		}
+	}
+	return Token{Kind: kind, Text: l.src[start.Offset:l.pos], Start: start, End: l.pposition()}
+}
+
+// nextInString lexes the contents of a string literal: a run of plain text
+// up to the next interpolation, escape, or the closing delimiter.
+func (l *Lexer) nextInString() Token {
+	start := l.pposition()
+	if l.pos >= len(l.src) {
+		// Unterminated string/indented-string literal: there's no closing
+		// delimiter left to pop the frame with, so — like nextNormal — just
+		// report EOF. Tokenize stops on the first TokenEOF, so the frame
+		// being left open on the stack is harmless.
+		return Token{Kind: TokenEOF, Start: start, End: start}
+	}
+	delim := l.stack[len(l.stack)-1].delim
+
+	if l.startsWith(delim) {
+		l.advanceN(len(delim))
+		l.stack = l.stack[:len(l.stack)-1]
+		return Token{Kind: TokenStringEnd, Text: delim, Start: start, End: l.pposition()}
+	}
+	if delim == "''" && l.startsWith("''${") {
+		// `''${` is the indented-string escape for a literal `${`.
+		l.advanceN(3)
+		return Token{Kind: TokenStringPart, Text: "$", Start: start, End: l.pposition()}
+	}
+	if delim == "''" && l.startsWith("'''") {
+		l.advanceN(3)
+		return Token{Kind: TokenStringPart, Text: "''", Start: start, End: l.pposition()}
+	}
+	if l.startsWith("${") {
+		l.advanceN(2)
+		l.stack = append(l.stack, lexFrame{inString: false, braceDepth: 0})
+		return Token{Kind: TokenInterpStart, Text: "${", Start: start, End: l.pposition()}
+	}
+
+	var b strings.Builder
+	for l.pos < len(l.src) {
+		if l.startsWith(delim) || l.startsWith("${") {
+			break
+		}
+		if delim == "''" && l.startsWith("''${") {
+			break
+		}
+		if delim == "\"" && l.peekByte() == '\\' {
+			l.advance()
+			if l.pos < len(l.src) {
+				b.WriteByte(l.advance())
+			}
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(l.src[l.pos:])
+		b.WriteRune(r)
+		for i := 0; i < size; i++ {
+			l.advance()
+		}
+	}
+	return Token{Kind: TokenStringPart, Text: b.String(), Start: start, End: l.pposition()}
+}
+
+func (l *Lexer) lexOperator(start Position) Token {
+	two := func(a, b byte, kind TokenKind) (Token, bool) {
+		if l.peekByte() == a && l.peekByteAt(1) == b {
+			l.advanceN(2)
+			return Token{Kind: kind, Text: l.src[start.Offset:l.pos], Start: start, End: l.pposition()}, true
+		}
+		return Token{}, false
+	}
+	if tok, ok := two('+', '+', TokenConcat); ok {
+		return tok
+	}
+	if tok, ok := two('/', '/', TokenUpdate); ok {
+		return tok
+	}
+	if tok, ok := two('=', '=', TokenEq); ok {
+		return tok
+	}
+	if tok, ok := two('!', '=', TokenNeq); ok {
+		return tok
+	}
+	if tok, ok := two('<', '=', TokenLte); ok {
+		return tok
+	}
+	if tok, ok := two('>', '=', TokenGte); ok {
+		return tok
+	}
+	if tok, ok := two('&', '&', TokenAnd); ok {
+		return tok
+	}
+	if tok, ok := two('|', '|', TokenOr); ok {
+		return tok
+	}
+	if tok, ok := two('-', '>', TokenImplies); ok {
+		return tok
+	}
+	if l.startsWith("...") {
+		l.advanceN(3)
+		return Token{Kind: TokenEllipsis, Text: "...", Start: start, End: l.pposition()}
+	}
+
+	b := l.advance()
+	single := map[byte]TokenKind{
+		'(': TokenLParen, ')': TokenRParen,
+		'{': TokenLBrace, '}': TokenRBrace,
+		'[': TokenLBracket, ']': TokenRBracket,
+		';': TokenSemicolon, ':': TokenColon, ',': TokenComma,
+		'.': TokenDot, '@': TokenAt, '?': TokenQuestion,
+		'=': TokenAssign, '+': TokenPlus, '-': TokenMinus,
+		'*': TokenStar, '/': TokenSlash, '<': TokenLt, '>': TokenGt,
+		'!': TokenNot,
+	}
+	kind, ok := single[b]
+	if !ok {
+		kind = TokenError
+	}
+	return Token{Kind: kind, Text: string(b), Start: start, End: l.pposition()}
+}
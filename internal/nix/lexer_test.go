@@ -0,0 +1,30 @@
+package nix
+
+import (
+	"testing"
+	"time"
+)
+
+// tokenizeWithTimeout runs Tokenize on its own goroutine and fails the test
+// rather than hanging forever if it doesn't reach TokenEOF in time.
+func tokenizeWithTimeout(t *testing.T, src string) []Token {
+	t.Helper()
+	done := make(chan []Token, 1)
+	go func() { done <- Tokenize(src) }()
+	select {
+	case toks := <-done:
+		return toks
+	case <-time.After(2 * time.Second):
+		t.Fatalf("Tokenize(%q) did not terminate", src)
+		return nil
+	}
+}
+
+func TestTokenizeUnterminatedStringReachesEOF(t *testing.T) {
+	for _, src := range []string{`"foo`, `''foo`, `"foo ${ "bar`} {
+		toks := tokenizeWithTimeout(t, src)
+		if len(toks) == 0 || toks[len(toks)-1].Kind != TokenEOF {
+			t.Fatalf("Tokenize(%q) = %v, want it to end in TokenEOF", src, toks)
+		}
+	}
+}
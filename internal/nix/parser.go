@@ -0,0 +1,520 @@
+package nix
+
+import (
+	"fmt"
+	"strconv"
+)
+
+func parseIntLiteral(s string) int64 {
+	v, _ := strconv.ParseInt(s, 10, 64)
+	return v
+}
+
+func parseFloatLiteral(s string) float64 {
+	v, _ := strconv.ParseFloat(s, 64)
+	return v
+}
+
+// Parser is a hand-written recursive-descent parser over a pre-tokenized
+// Nix source file. It recovers from errors by recording a SyntaxError and
+// continuing, so a single typo in a large file doesn't prevent the rest of
+// the document from being analyzed.
+type Parser struct {
+	tokens []Token
+	pos    int
+	errors []SyntaxError
+}
+
+// Parse lexes and parses src, returning the resulting File. The File is
+// always non-nil, even when Errors is non-empty: callers such as the
+// analysis package rely on getting a best-effort AST out of partially
+// invalid documents while the user is mid-edit.
+func Parse(src string) *File {
+	p := &Parser{tokens: Tokenize(src)}
+	root := p.parseExpr()
+	if p.cur().Kind != TokenEOF {
+		p.errorf("unexpected trailing input %q", p.cur().Text)
+	}
+	return &File{Root: root, Errors: p.errors}
+}
+
+func (p *Parser) cur() Token { return p.tokAt(p.pos) }
+
+func (p *Parser) peek(n int) Token { return p.tokAt(p.pos + n) }
+
+func (p *Parser) tokAt(i int) Token {
+	if i < 0 || i >= len(p.tokens) {
+		return p.tokens[len(p.tokens)-1] // EOF
+	}
+	return p.tokens[i]
+}
+
+func (p *Parser) advance() Token {
+	t := p.cur()
+	if t.Kind != TokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *Parser) expect(kind TokenKind) Token {
+	if p.cur().Kind == kind {
+		return p.advance()
+	}
+	p.errorf("expected %s, got %q", tokenKindName(kind), p.cur().Text)
+	return p.cur()
+}
+
+func (p *Parser) errorf(format string, args ...any) {
+	t := p.cur()
+	p.errors = append(p.errors, SyntaxError{
+		Message: fmt.Sprintf(format, args...),
+		Span:    Span{Start: t.Start, End: t.End},
+	})
+}
+
+func tokenKindName(k TokenKind) string {
+	if n, ok := tokenNames[k]; ok {
+		return n
+	}
+	return "token"
+}
+
+var tokenNames = map[TokenKind]string{
+	TokenRBrace: "'}'", TokenLBrace: "'{'", TokenRParen: "')'", TokenLParen: "'('",
+	TokenRBracket: "']'", TokenLBracket: "'['", TokenColon: "':'", TokenSemicolon: "';'",
+	TokenAssign: "'='", TokenIn: "'in'", TokenThen: "'then'", TokenElse: "'else'",
+}
+
+// --- top-level expression dispatch ---
+
+func (p *Parser) parseExpr() Expr {
+	switch p.cur().Kind {
+	case TokenLet:
+		return p.parseLetIn()
+	case TokenWith:
+		return p.parseWith()
+	case TokenIf:
+		return p.parseIf()
+	case TokenAssert:
+		return p.parseAssert()
+	}
+	if p.isFunctionStart() {
+		return p.parseFunction()
+	}
+	return p.parseOp(1)
+}
+
+func (p *Parser) parseLetIn() Expr {
+	start := p.advance().Start // 'let'
+	bindings := p.parseBindingsUntil(TokenIn)
+	p.expect(TokenIn)
+	body := p.parseExpr()
+	return &LetIn{baseNode{Span{start, body.Span().End}}, bindings, body}
+}
+
+func (p *Parser) parseWith() Expr {
+	start := p.advance().Start // 'with'
+	e := p.parseExpr()
+	p.expect(TokenSemicolon)
+	body := p.parseExpr()
+	return &With{baseNode{Span{start, body.Span().End}}, e, body}
+}
+
+func (p *Parser) parseIf() Expr {
+	start := p.advance().Start // 'if'
+	cond := p.parseExpr()
+	p.expect(TokenThen)
+	then := p.parseExpr()
+	p.expect(TokenElse)
+	els := p.parseExpr()
+	return &If{baseNode{Span{start, els.Span().End}}, cond, then, els}
+}
+
+func (p *Parser) parseAssert() Expr {
+	start := p.advance().Start // 'assert'
+	cond := p.parseExpr()
+	p.expect(TokenSemicolon)
+	body := p.parseExpr()
+	return &Assert{baseNode{Span{start, body.Span().End}}, cond, body}
+}
+
+// --- function literals ---
+
+func (p *Parser) isFunctionStart() bool {
+	t := p.cur()
+	switch t.Kind {
+	case TokenIdent:
+		n1 := p.peek(1)
+		if n1.Kind == TokenColon {
+			return true
+		}
+		return n1.Kind == TokenAt && p.peek(2).Kind == TokenLBrace
+	case TokenLBrace:
+		end := p.matchBrace(p.pos)
+		if end < 0 {
+			return false
+		}
+		after := p.tokAt(end + 1)
+		return after.Kind == TokenColon || after.Kind == TokenAt
+	}
+	return false
+}
+
+func (p *Parser) matchBrace(open int) int {
+	depth := 0
+	for i := open; i < len(p.tokens); i++ {
+		switch p.tokens[i].Kind {
+		case TokenLBrace:
+			depth++
+		case TokenRBrace:
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func (p *Parser) parseFunction() Expr {
+	start := p.cur().Start
+	if p.cur().Kind == TokenIdent && p.peek(1).Kind == TokenAt {
+		name := p.advance().Text // ident
+		p.advance()              // '@'
+		pat := p.parsePattern()
+		pat.Bind = name
+		p.expect(TokenColon)
+		body := p.parseExpr()
+		return &Function{baseNode{Span{start, body.Span().End}}, pat, body}
+	}
+	if p.cur().Kind == TokenIdent {
+		name := p.advance().Text
+		p.expect(TokenColon)
+		body := p.parseExpr()
+		return &Function{baseNode{Span{start, body.Span().End}}, &IdentParam{baseNode{Span{start, start}}, name}, body}
+	}
+	pat := p.parsePattern()
+	if p.cur().Kind == TokenAt {
+		p.advance()
+		pat.Bind = p.expect(TokenIdent).Text
+	}
+	p.expect(TokenColon)
+	body := p.parseExpr()
+	return &Function{baseNode{Span{start, body.Span().End}}, pat, body}
+}
+
+func (p *Parser) parsePattern() *PatternParam {
+	start := p.expect(TokenLBrace).Start
+	var fields []PatternField
+	ellipsis := false
+	for p.cur().Kind != TokenRBrace && p.cur().Kind != TokenEOF {
+		if p.cur().Kind == TokenEllipsis {
+			p.advance()
+			ellipsis = true
+			break
+		}
+		fstart := p.cur().Start
+		name := p.expect(TokenIdent).Text
+		var def Expr
+		if p.cur().Kind == TokenQuestion {
+			p.advance()
+			def = p.parseExpr()
+		}
+		fields = append(fields, PatternField{Span{fstart, p.cur().Start}, name, def})
+		if p.cur().Kind == TokenComma {
+			p.advance()
+			continue
+		}
+		break
+	}
+	end := p.expect(TokenRBrace).End
+	return &PatternParam{baseNode{Span{start, end}}, fields, ellipsis, ""}
+}
+
+// --- binary/unary operator precedence climbing ---
+
+type opInfo struct {
+	prec       int
+	rightAssoc bool
+}
+
+// binOps mirrors Nix's yacc precedence declarations (lowest to highest):
+// implication, or, and, equality, comparison, update, add/sub, mul/div,
+// concat. `?` (has-attr) and unary `-`/`!` are handled outside this table
+// since their operands aren't plain sub-expressions.
+var binOps = map[TokenKind]opInfo{
+	TokenImplies: {1, true},
+	TokenOr:      {2, false},
+	TokenAnd:     {3, false},
+	TokenEq:      {4, false},
+	TokenNeq:     {4, false},
+	TokenLt:      {5, false},
+	TokenLte:     {5, false},
+	TokenGt:      {5, false},
+	TokenGte:     {5, false},
+	TokenUpdate:  {6, true},
+	TokenPlus:    {7, false},
+	TokenMinus:   {7, false},
+	TokenStar:    {8, false},
+	TokenSlash:   {8, false},
+	TokenConcat:  {9, true},
+}
+
+func (p *Parser) parseOp(minPrec int) Expr {
+	left := p.parseOperand()
+	for {
+		op, ok := binOps[p.cur().Kind]
+		if !ok || op.prec < minPrec {
+			return left
+		}
+		opTok := p.advance()
+		nextMin := op.prec + 1
+		if op.rightAssoc {
+			nextMin = op.prec
+		}
+		right := p.parseOp(nextMin)
+		left = &BinaryOp{baseNode{Span{left.Span().Start, right.Span().End}}, opTok.Kind, left, right}
+	}
+}
+
+// parseOperand parses a unary-minus/not/has-attr wrapped term: the tightest
+// binding level above the operator-precedence table.
+func (p *Parser) parseOperand() Expr {
+	term := p.parseUnary()
+	if p.cur().Kind == TokenQuestion {
+		p.advance()
+		path := p.parseAttrPath()
+		end := term.Span().End
+		if len(path) > 0 {
+			end = path[len(path)-1].Span.End
+		}
+		term = &HasAttr{baseNode{Span{term.Span().Start, end}}, term, path}
+	}
+	return term
+}
+
+func (p *Parser) parseUnary() Expr {
+	switch p.cur().Kind {
+	case TokenMinus:
+		start := p.advance().Start
+		e := p.parseUnary()
+		return &UnaryOp{baseNode{Span{start, e.Span().End}}, TokenMinus, e}
+	case TokenNot:
+		start := p.advance().Start
+		e := p.parseUnary()
+		return &UnaryOp{baseNode{Span{start, e.Span().End}}, TokenNot, e}
+	}
+	return p.parseApp()
+}
+
+// parseApp parses left-associative function application: a run of
+// select-level terms separated only by whitespace, e.g. `f x y`.
+func (p *Parser) parseApp() Expr {
+	left := p.parseSelect()
+	for p.startsTerm(p.cur()) {
+		arg := p.parseSelect()
+		left = &Apply{baseNode{Span{left.Span().Start, arg.Span().End}}, left, arg}
+	}
+	return left
+}
+
+func (p *Parser) startsTerm(t Token) bool {
+	switch t.Kind {
+	case TokenIdent, TokenInt, TokenFloat, TokenStringStart, TokenPath, TokenSPath,
+		TokenURI, TokenLParen, TokenLBrace, TokenLBracket, TokenRec:
+		return true
+	}
+	return false
+}
+
+func (p *Parser) parseSelect() Expr {
+	e := p.parsePrimary()
+	if p.cur().Kind == TokenDot {
+		p.advance()
+		path := p.parseAttrPath()
+		end := path[len(path)-1].Span.End
+		var def Expr
+		if p.cur().Kind == TokenOr_ {
+			p.advance()
+			def = p.parseApp()
+			end = def.Span().End
+		}
+		e = &Select{baseNode{Span{e.Span().Start, end}}, e, path, def}
+	}
+	return e
+}
+
+func (p *Parser) parseAttrPath() []AttrPathSegment {
+	segs := []AttrPathSegment{p.parseAttrSegment()}
+	for p.cur().Kind == TokenDot {
+		p.advance()
+		segs = append(segs, p.parseAttrSegment())
+	}
+	return segs
+}
+
+func (p *Parser) parseAttrSegment() AttrPathSegment {
+	t := p.cur()
+	switch t.Kind {
+	case TokenIdent, TokenOr_:
+		p.advance()
+		return AttrPathSegment{Span: Span{t.Start, t.End}, Name: t.Text}
+	case TokenStringStart:
+		str := p.parseString()
+		if len(str.Parts) == 1 && str.Parts[0].Interp == nil {
+			return AttrPathSegment{Span: str.Span(), Name: str.Parts[0].Literal}
+		}
+		return AttrPathSegment{Span: str.Span(), Expr: str}
+	case TokenInterpStart:
+		start := p.advance().Start
+		e := p.parseExpr()
+		end := p.expect(TokenInterpEnd).End
+		return AttrPathSegment{Span: Span{start, end}, Expr: e}
+	default:
+		p.errorf("expected attribute name, got %q", t.Text)
+		return AttrPathSegment{Span: Span{t.Start, t.End}, Name: t.Text}
+	}
+}
+
+// --- primaries ---
+
+func (p *Parser) parsePrimary() Expr {
+	t := p.cur()
+	switch t.Kind {
+	case TokenIdent:
+		p.advance()
+		switch t.Text {
+		case "true":
+			return &Bool{baseNode{Span{t.Start, t.End}}, true}
+		case "false":
+			return &Bool{baseNode{Span{t.Start, t.End}}, false}
+		case "null":
+			return &Null{baseNode{Span{t.Start, t.End}}}
+		default:
+			return &Ident{baseNode{Span{t.Start, t.End}}, t.Text}
+		}
+	case TokenInt:
+		p.advance()
+		return &Int{baseNode{Span{t.Start, t.End}}, parseIntLiteral(t.Text)}
+	case TokenFloat:
+		p.advance()
+		return &Float{baseNode{Span{t.Start, t.End}}, parseFloatLiteral(t.Text)}
+	case TokenPath:
+		p.advance()
+		return &PathLit{baseNode{Span{t.Start, t.End}}, t.Text}
+	case TokenSPath:
+		p.advance()
+		return &SearchPath{baseNode{Span{t.Start, t.End}}, t.Text}
+	case TokenURI:
+		p.advance()
+		return &URILit{baseNode{Span{t.Start, t.End}}, t.Text}
+	case TokenStringStart:
+		return p.parseString()
+	case TokenLParen:
+		// Parens only group for precedence; the inner expression's own
+		// type and span are what analysis and other callers see.
+		p.advance()
+		e := p.parseExpr()
+		p.expect(TokenRParen)
+		return e
+	case TokenLBracket:
+		return p.parseList()
+	case TokenRec:
+		p.advance()
+		return p.parseAttrSet(true, t.Start)
+	case TokenLBrace:
+		return p.parseAttrSet(false, t.Start)
+	default:
+		p.errorf("unexpected token %q", t.Text)
+		p.advance()
+		return &Null{baseNode{Span{t.Start, t.End}}}
+	}
+}
+
+func (p *Parser) parseString() *Str {
+	start := p.advance().Start // StringStart
+	var parts []StringPart
+	for {
+		switch p.cur().Kind {
+		case TokenStringPart:
+			parts = append(parts, StringPart{Literal: p.cur().Text})
+			p.advance()
+		case TokenInterpStart:
+			p.advance()
+			e := p.parseExpr()
+			p.expect(TokenInterpEnd)
+			parts = append(parts, StringPart{Interp: e})
+		case TokenStringEnd:
+			end := p.advance().End
+			return &Str{baseNode{Span{start, end}}, parts}
+		default:
+			p.errorf("unterminated string literal")
+			return &Str{baseNode{Span{start, p.cur().Start}}, parts}
+		}
+	}
+}
+
+func (p *Parser) parseList() Expr {
+	start := p.advance().Start // '['
+	var elems []Expr
+	for p.cur().Kind != TokenRBracket && p.cur().Kind != TokenEOF {
+		before := p.pos
+		elems = append(elems, p.parseSelect())
+		if p.pos == before {
+			p.advance() // guarantee forward progress on malformed input
+		}
+	}
+	end := p.expect(TokenRBracket).End
+	return &ListExpr{baseNode{Span{start, end}}, elems}
+}
+
+func (p *Parser) parseAttrSet(rec bool, start Position) Expr {
+	p.expect(TokenLBrace)
+	bindings := p.parseBindingsUntil(TokenRBrace)
+	end := p.expect(TokenRBrace).End
+	return &AttrSet{baseNode{Span{start, end}}, rec, bindings}
+}
+
+// --- bindings ---
+
+func (p *Parser) parseBindingsUntil(stop TokenKind) []Binding {
+	var bindings []Binding
+	for p.cur().Kind != stop && p.cur().Kind != TokenEOF {
+		before := p.pos
+		if p.cur().Kind == TokenInherit {
+			bindings = append(bindings, p.parseInherit())
+		} else {
+			bindings = append(bindings, p.parseAttrBinding())
+		}
+		if p.pos == before {
+			p.advance() // guarantee forward progress on malformed input
+		}
+	}
+	return bindings
+}
+
+func (p *Parser) parseInherit() Binding {
+	start := p.advance().Start // 'inherit'
+	var from Expr
+	if p.cur().Kind == TokenLParen {
+		p.advance()
+		from = p.parseExpr()
+		p.expect(TokenRParen)
+	}
+	var names []AttrPathSegment
+	for p.cur().Kind == TokenIdent || p.cur().Kind == TokenStringStart || p.cur().Kind == TokenOr_ {
+		names = append(names, p.parseAttrSegment())
+	}
+	end := p.expect(TokenSemicolon).End
+	return &InheritBinding{baseNode{Span{start, end}}, from, names}
+}
+
+func (p *Parser) parseAttrBinding() Binding {
+	start := p.cur().Start
+	path := p.parseAttrPath()
+	p.expect(TokenAssign)
+	value := p.parseExpr()
+	end := p.expect(TokenSemicolon).End
+	return &AttrBinding{baseNode{Span{start, end}}, path, value}
+}
@@ -0,0 +1,93 @@
+package nix
+
+import "testing"
+
+func TestParseNoErrors(t *testing.T) {
+	cases := []string{
+		`1 + 2 * 3`,
+		`let x = 1; y = 2; in x + y`,
+		`{ a = 1; b = 2; }`,
+		`rec { a = 1; b = a + 1; }`,
+		`with pkgs; [ hello world ]`,
+		`x: x + 1`,
+		`{ a, b ? 2, ... }@args: a + b`,
+		`args@{ a, b ? 2, ... }: a + b`,
+		`if a then b else c`,
+		`assert a == b; c`,
+		`pkgs.lib.strings.concat`,
+		`a.${name} or default`,
+		`{ inherit (pkgs) hello world; }`,
+		`"hello ${name}!"`,
+		`''
+		multi
+		line ${x}
+		''`,
+		`import ./foo.nix`,
+		`[ 1 2 3 ]`,
+		`a ? b.c`,
+		`a // b`,
+		`-1 + -x`,
+		`!a && b`,
+	}
+	for _, src := range cases {
+		f := Parse(src)
+		if len(f.Errors) != 0 {
+			t.Errorf("Parse(%q): unexpected errors: %v", src, f.Errors)
+		}
+		if f.Root == nil {
+			t.Errorf("Parse(%q): nil root", src)
+		}
+	}
+}
+
+func TestParseLetBindingNames(t *testing.T) {
+	f := Parse(`let x = 1; y = 2; in x`)
+	let, ok := f.Root.(*LetIn)
+	if !ok {
+		t.Fatalf("root is %T, want *LetIn", f.Root)
+	}
+	if len(let.Bindings) != 2 {
+		t.Fatalf("got %d bindings, want 2", len(let.Bindings))
+	}
+	ab, ok := let.Bindings[0].(*AttrBinding)
+	if !ok || ab.Path[0].Name != "x" {
+		t.Fatalf("first binding = %#v, want attr x", let.Bindings[0])
+	}
+}
+
+func TestParseAttrSetIsRec(t *testing.T) {
+	f := Parse(`rec { a = 1; }`)
+	set, ok := f.Root.(*AttrSet)
+	if !ok || !set.Rec {
+		t.Fatalf("root = %#v, want rec attrset", f.Root)
+	}
+}
+
+func TestParseFunctionPattern(t *testing.T) {
+	f := Parse(`{ a, b ? 2, ... }: a`)
+	fn, ok := f.Root.(*Function)
+	if !ok {
+		t.Fatalf("root is %T, want *Function", f.Root)
+	}
+	pat, ok := fn.Param.(*PatternParam)
+	if !ok {
+		t.Fatalf("param is %T, want *PatternParam", fn.Param)
+	}
+	if len(pat.Fields) != 2 || !pat.Ellipsis {
+		t.Fatalf("pattern = %#v", pat)
+	}
+}
+
+func TestParseSyntaxErrorRecovers(t *testing.T) {
+	f := Parse(`{ a = ; b = 2; }`)
+	if len(f.Errors) == 0 {
+		t.Fatalf("expected a syntax error")
+	}
+	set, ok := f.Root.(*AttrSet)
+	if !ok {
+		t.Fatalf("root is %T, want *AttrSet despite error", f.Root)
+	}
+	if len(set.Bindings) != 2 {
+		t.Fatalf("got %d bindings, want 2 (recovered)", len(set.Bindings))
+	}
+}
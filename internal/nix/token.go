@@ -0,0 +1,103 @@
+// Package nix implements a lexer and recursive-descent parser for the Nix
+// expression language, producing an AST suitable for scope analysis,
+// diagnostics, and editor features such as completion and hover.
+package nix
+
+// TokenKind identifies the lexical category of a Token.
+type TokenKind int
+
+const (
+	TokenEOF TokenKind = iota
+	TokenError
+
+	TokenIdent
+	TokenInt
+	TokenFloat
+	TokenPath
+	TokenSPath // <search-path>
+	TokenURI
+
+	// String pieces. A string literal is lexed as an opening quote,
+	// interleaved TokenStringPart/TokenInterpStart/TokenInterpEnd tokens,
+	// and a closing quote, so that ${...} interpolations can be parsed as
+	// ordinary expressions nested inside the string.
+	TokenStringStart // " or ''
+	TokenStringEnd
+	TokenStringPart
+	TokenInterpStart // ${
+	TokenInterpEnd   // }
+
+	// Punctuation
+	TokenLParen
+	TokenRParen
+	TokenLBrace
+	TokenRBrace
+	TokenLBracket
+	TokenRBracket
+	TokenSemicolon
+	TokenColon
+	TokenComma
+	TokenDot
+	TokenAt
+	TokenQuestion
+	TokenEllipsis
+
+	// Operators
+	TokenAssign
+	TokenPlus
+	TokenMinus
+	TokenStar
+	TokenSlash
+	TokenConcat // ++
+	TokenUpdate // //
+	TokenEq     // ==
+	TokenNeq    // !=
+	TokenLt
+	TokenLte
+	TokenGt
+	TokenGte
+	TokenAnd // &&
+	TokenOr  // ||
+	TokenImplies
+	TokenNot
+
+	// Keywords
+	TokenIf
+	TokenThen
+	TokenElse
+	TokenLet
+	TokenIn
+	TokenWith
+	TokenRec
+	TokenInherit
+	TokenAssert
+	TokenOr_ // `or` used by attribute-default selects, contextual keyword
+)
+
+var keywords = map[string]TokenKind{
+	"if":      TokenIf,
+	"then":    TokenThen,
+	"else":    TokenElse,
+	"let":     TokenLet,
+	"in":      TokenIn,
+	"with":    TokenWith,
+	"rec":     TokenRec,
+	"inherit": TokenInherit,
+	"assert":  TokenAssert,
+	"or":      TokenOr_,
+}
+
+// Position is a 0-based line/column location, matching LSP conventions.
+type Position struct {
+	Line   int
+	Column int
+	Offset int
+}
+
+// Token is a single lexical token with its source span.
+type Token struct {
+	Kind  TokenKind
+	Text  string
+	Start Position
+	End   Position
+}
@@ -0,0 +1,129 @@
+package nix
+
+// Contains reports whether pos falls within s, inclusive of both ends so a
+// cursor sitting exactly at a token boundary still resolves.
+func (s Span) Contains(pos Position) bool {
+	return pos.Offset >= s.Start.Offset && pos.Offset <= s.End.Offset
+}
+
+// Children returns the direct child nodes of n, in source order. It is the
+// basis for FindPath and any other position- or AST-driven traversal
+// (completion context, semantic tokens, hover).
+func Children(n Node) []Node {
+	switch v := n.(type) {
+	case *Str:
+		var out []Node
+		for _, p := range v.Parts {
+			if p.Interp != nil {
+				out = append(out, p.Interp)
+			}
+		}
+		return out
+	case *ListExpr:
+		out := make([]Node, 0, len(v.Elems))
+		for _, e := range v.Elems {
+			out = append(out, e)
+		}
+		return out
+	case *AttrSet:
+		out := make([]Node, 0, len(v.Bindings))
+		for _, b := range v.Bindings {
+			out = append(out, b)
+		}
+		return out
+	case *LetIn:
+		out := make([]Node, 0, len(v.Bindings)+1)
+		for _, b := range v.Bindings {
+			out = append(out, b)
+		}
+		return append(out, v.Body)
+	case *With:
+		return []Node{v.Expr, v.Body}
+	case *Function:
+		return []Node{v.Param, v.Body}
+	case *PatternParam:
+		var out []Node
+		for _, f := range v.Fields {
+			if f.Default != nil {
+				out = append(out, f.Default)
+			}
+		}
+		return out
+	case *Apply:
+		return []Node{v.Fn, v.Arg}
+	case *UnaryOp:
+		return []Node{v.Expr}
+	case *BinaryOp:
+		return []Node{v.Left, v.Right}
+	case *If:
+		return []Node{v.Cond, v.Then, v.Else}
+	case *Assert:
+		return []Node{v.Cond, v.Body}
+	case *Select:
+		out := append([]Node{v.Expr}, attrPathChildren(v.Path)...)
+		if v.Default != nil {
+			out = append(out, v.Default)
+		}
+		return out
+	case *HasAttr:
+		return append([]Node{v.Expr}, attrPathChildren(v.Path)...)
+	case *AttrBinding:
+		return append(attrPathChildren(v.Path), v.Value)
+	case *InheritBinding:
+		var out []Node
+		if v.From != nil {
+			out = append(out, v.From)
+		}
+		return append(out, attrPathChildren(v.Names)...)
+	default:
+		// Ident, Int, Float, Bool, Null, PathLit, SearchPath, URILit,
+		// IdentParam: leaves with no sub-nodes.
+		return nil
+	}
+}
+
+func attrPathChildren(segs []AttrPathSegment) []Node {
+	var out []Node
+	for _, s := range segs {
+		if s.Expr != nil {
+			out = append(out, s.Expr)
+		}
+	}
+	return out
+}
+
+// Walk visits root and every descendant in pre-order, depth first.
+func Walk(root Node, visit func(Node)) {
+	if root == nil {
+		return
+	}
+	visit(root)
+	for _, c := range Children(root) {
+		Walk(c, visit)
+	}
+}
+
+// FindPath returns the chain of nodes from root down to the innermost node
+// whose span contains pos, root first. It returns nil if pos falls outside
+// root's span entirely.
+func FindPath(root Node, pos Position) []Node {
+	if root == nil || !root.Span().Contains(pos) {
+		return nil
+	}
+	path := []Node{root}
+	cur := root
+	for {
+		var next Node
+		for _, c := range Children(cur) {
+			if c != nil && c.Span().Contains(pos) {
+				next = c
+				break
+			}
+		}
+		if next == nil {
+			return path
+		}
+		path = append(path, next)
+		cur = next
+	}
+}
@@ -0,0 +1,29 @@
+package nix
+
+import "testing"
+
+func TestFindPathFindsInnermostIdent(t *testing.T) {
+	src := `let x = 1; in x + 1`
+	f := Parse(src)
+
+	// position of the `x` reference in the body, "let x = 1; in x + 1"
+	//                                                           ^ offset 15
+	pos := Position{Offset: 15}
+	path := FindPath(f.Root, pos)
+	if len(path) == 0 {
+		t.Fatalf("FindPath returned empty path")
+	}
+	innermost := path[len(path)-1]
+	ident, ok := innermost.(*Ident)
+	if !ok || ident.Name != "x" {
+		t.Fatalf("innermost node = %#v, want Ident(x)", innermost)
+	}
+}
+
+func TestFindPathOutsideSpan(t *testing.T) {
+	f := Parse(`1 + 2`)
+	path := FindPath(f.Root, Position{Offset: 1000})
+	if path != nil {
+		t.Fatalf("expected nil path outside span, got %v", path)
+	}
+}
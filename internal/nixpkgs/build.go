@@ -0,0 +1,148 @@
+package nixpkgs
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// maxLibDepth bounds how many levels of lib's nested attrsets queryLibAttrs
+// descends into, so indexing lib can't spin forever on a set deep enough to
+// be effectively unbounded (or, with a cyclic-looking alias, truly so).
+const maxLibDepth = 2
+
+// Config controls which binaries Build shells out to.
+type Config struct {
+	// NixEnv is the `nix-env` binary, used to list packages; defaults to
+	// "nix-env" resolved via PATH.
+	NixEnv string
+	// NixInstantiate is the `nix-instantiate` binary, used to list lib's
+	// nested attribute names and to locate the nixpkgs store path for
+	// caching; defaults to "nix-instantiate".
+	NixInstantiate string
+}
+
+func (c Config) nixEnv() string {
+	if c.NixEnv == "" {
+		return "nix-env"
+	}
+	return c.NixEnv
+}
+
+func (c Config) nixInstantiate() string {
+	if c.NixInstantiate == "" {
+		return "nix-instantiate"
+	}
+	return c.NixInstantiate
+}
+
+// Build runs `nix-env -qaP --json` and a bounded-depth walk of lib's
+// attrsets once, assembling a fresh Index from their output.
+func Build(cfg Config) (*Index, error) {
+	packages, err := queryPackages(cfg.nixEnv())
+	if err != nil {
+		return nil, err
+	}
+	libAttrs, err := queryLibAttrs(cfg.nixInstantiate())
+	if err != nil {
+		return nil, err
+	}
+	return &Index{Packages: packages, LibAttrs: libAttrs}, nil
+}
+
+// StorePath resolves the nixpkgs store path Build's results are valid for,
+// so callers can key a disk cache on it and invalidate the cache the
+// moment the channel or flake input is updated.
+func StorePath(cfg Config) (string, error) {
+	out, err := run(cfg.nixInstantiate(), "--eval", "-E", "<nixpkgs>")
+	return strings.TrimSpace(string(out)), err
+}
+
+type nixEnvEntry struct {
+	PName string `json:"pname"`
+	Name  string `json:"name"`
+	Meta  struct {
+		Description string `json:"description"`
+	} `json:"meta"`
+}
+
+func queryPackages(bin string) (map[string]Attr, error) {
+	out, err := run(bin, "-qaP", "--json")
+	if err != nil {
+		return nil, err
+	}
+	var entries map[string]nixEnvEntry
+	if err := json.Unmarshal(out, &entries); err != nil {
+		return nil, err
+	}
+	packages := make(map[string]Attr, len(entries))
+	for attrPath, entry := range entries {
+		version := strings.TrimPrefix(entry.Name, entry.PName+"-")
+		packages[attrPath] = Attr{
+			Path:        attrPath,
+			Kind:        KindPackage,
+			Version:     version,
+			Description: entry.Meta.Description,
+		}
+	}
+	return packages, nil
+}
+
+// libWalkExpr builds a `nix-instantiate --eval` expression that walks lib's
+// attrsets up to maxLibDepth levels deep via builtins.concatMap, emitting a
+// flat list of {path, isSet} entries (path relative to lib, dotted). Each
+// attribute access is guarded by builtins.tryEval: lib has a handful of
+// attributes that throw when forced (deprecation aliases and the like), and
+// one throwing attribute shouldn't fail the whole walk.
+func libWalkExpr() string {
+	return fmt.Sprintf(`
+let
+  lib = (import <nixpkgs> {}).lib;
+  walk = depth: prefix: set:
+    builtins.concatMap (name:
+      let
+        path = if prefix == "" then name else prefix + "." + name;
+        ok = builtins.tryEval (builtins.isAttrs set.${name});
+        isSet = ok.success && ok.value;
+      in
+      [ { inherit path isSet; } ]
+      ++ (if isSet && depth < %d then walk (depth + 1) path set.${name} else [ ])
+    ) (builtins.attrNames set);
+in walk 0 "" lib
+`, maxLibDepth)
+}
+
+func queryLibAttrs(bin string) (map[string]Attr, error) {
+	out, err := run(bin, "--eval", "--json", "-E", libWalkExpr())
+	if err != nil {
+		return nil, err
+	}
+	var entries []struct {
+		Path  string `json:"path"`
+		IsSet bool   `json:"isSet"`
+	}
+	if err := json.Unmarshal(out, &entries); err != nil {
+		return nil, err
+	}
+	attrs := make(map[string]Attr, len(entries))
+	for _, e := range entries {
+		kind := KindValue
+		if e.IsSet {
+			kind = KindSet
+		}
+		attrs[e.Path] = Attr{Path: e.Path, Kind: kind}
+	}
+	return attrs, nil
+}
+
+func run(bin string, args ...string) ([]byte, error) {
+	cmd := exec.Command(bin, args...)
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	if err := cmd.Run(); err != nil {
+		return nil, err
+	}
+	return stdout.Bytes(), nil
+}
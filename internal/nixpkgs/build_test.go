@@ -0,0 +1,49 @@
+package nixpkgs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fakeBin writes an executable shell script that echoes fixed stdout, so
+// Build can be exercised without the real nix-env/nix-instantiate binaries
+// (absent from this sandbox).
+func fakeBin(t *testing.T, stdout string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "fake-bin")
+	script := "#!/bin/sh\ncat <<'EOF'\n" + stdout + "\nEOF\n"
+	if err := os.WriteFile(path, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestBuildParsesPackagesAndLibAttrs(t *testing.T) {
+	nixEnv := fakeBin(t, `{"hello":{"pname":"hello","name":"hello-2.12.1","meta":{"description":"A friendly program"}}}`)
+	nixInstantiate := fakeBin(t, `[{"path":"strings","isSet":true},{"path":"strings.hasPrefix","isSet":false},{"path":"attrsets","isSet":true}]`)
+
+	idx, err := Build(Config{NixEnv: nixEnv, NixInstantiate: nixInstantiate})
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	attr, ok := idx.Packages["hello"]
+	if !ok {
+		t.Fatal("expected \"hello\" package in index")
+	}
+	if attr.Version != "2.12.1" {
+		t.Fatalf("version = %q, want 2.12.1", attr.Version)
+	}
+	if attr.Description != "A friendly program" {
+		t.Fatalf("description = %q", attr.Description)
+	}
+	if len(idx.LibAttrs) != 3 {
+		t.Fatalf("got %d lib attrs, want 3", len(idx.LibAttrs))
+	}
+	if idx.LibAttrs["strings"].Kind != KindSet {
+		t.Fatalf("strings kind = %v, want KindSet", idx.LibAttrs["strings"].Kind)
+	}
+	if idx.LibAttrs["strings.hasPrefix"].Kind != KindValue {
+		t.Fatalf("strings.hasPrefix kind = %v, want KindValue", idx.LibAttrs["strings.hasPrefix"].Kind)
+	}
+}
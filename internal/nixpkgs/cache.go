@@ -0,0 +1,102 @@
+package nixpkgs
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// cacheFile returns the on-disk path an Index for storePath is cached
+// under, inside the user's cache directory.
+func cacheFile(storePath string) (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(storePath))
+	return filepath.Join(dir, "nixos-lsp", fmt.Sprintf("index-%x.json", sum[:8])), nil
+}
+
+func loadCache(path string) (*Index, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var idx Index
+	if err := json.Unmarshal(data, &idx); err != nil {
+		return nil, err
+	}
+	return &idx, nil
+}
+
+func saveCache(path string, idx *Index) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(idx)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// metaCacheFile returns the on-disk path a MetaCache entry is stored under,
+// keyed by both the nixpkgs store path and the attribute path it's meta
+// for, unlike cacheFile which keys the whole Index on storePath alone.
+func metaCacheFile(storePath, attrPath string) (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(storePath + "\x00" + attrPath))
+	return filepath.Join(dir, "nixos-lsp", fmt.Sprintf("meta-%x.json", sum[:8])), nil
+}
+
+func loadMetaCache(path string) (*Meta, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var m Meta
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+func saveMetaCache(path string, m *Meta) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// LoadOrBuild returns the cached Index for the running nixpkgs, building
+// and caching a fresh one on a cache miss.
+func LoadOrBuild(cfg Config) (*Index, error) {
+	storePath, pathErr := StorePath(cfg)
+	if pathErr == nil {
+		if path, err := cacheFile(storePath); err == nil {
+			if idx, err := loadCache(path); err == nil {
+				return idx, nil
+			}
+		}
+	}
+
+	idx, err := Build(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if pathErr == nil {
+		if path, err := cacheFile(storePath); err == nil {
+			_ = saveCache(path, idx)
+		}
+	}
+	return idx, nil
+}
@@ -0,0 +1,31 @@
+package nixpkgs
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestCacheRoundTrip(t *testing.T) {
+	idx := testIndex()
+	path := filepath.Join(t.TempDir(), "index.json")
+
+	if err := saveCache(path, idx); err != nil {
+		t.Fatalf("saveCache: %v", err)
+	}
+	got, err := loadCache(path)
+	if err != nil {
+		t.Fatalf("loadCache: %v", err)
+	}
+	if len(got.Packages) != len(idx.Packages) {
+		t.Fatalf("got %d packages, want %d", len(got.Packages), len(idx.Packages))
+	}
+	if len(got.LibAttrs) != len(idx.LibAttrs) {
+		t.Fatalf("got %d lib attrs, want %d", len(got.LibAttrs), len(idx.LibAttrs))
+	}
+}
+
+func TestLoadCacheMissingFile(t *testing.T) {
+	if _, err := loadCache(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Fatal("expected an error for a missing cache file")
+	}
+}
@@ -0,0 +1,129 @@
+// Package nixpkgs builds and caches a flat index of nixpkgs attribute
+// names — packages from `nix-env -qaP --json` and lib's nested attribute
+// names, a few levels deep, from `nix-instantiate --eval --json` — so
+// completion can offer real `pkgs.<foo>` and `lib.<foo>.<bar>` candidates
+// without evaluating nixpkgs on every keystroke.
+package nixpkgs
+
+import "strings"
+
+// Kind classifies what an Attr's value looks like, as far as the index can
+// tell without evaluating it.
+type Kind int
+
+const (
+	KindPackage Kind = iota
+	KindSet
+	KindValue
+)
+
+// Attr is one entry in the index: a dotted attribute path plus whatever
+// metadata nix-env or nix-instantiate reported about it.
+type Attr struct {
+	Path        string
+	Kind        Kind
+	Version     string
+	Description string
+}
+
+// Name returns the last segment of Path, the part a completion item's
+// Label and InsertText should use.
+func (a Attr) Name() string {
+	if i := strings.LastIndexByte(a.Path, '.'); i >= 0 {
+		return a.Path[i+1:]
+	}
+	return a.Path
+}
+
+// Detail returns the short, human-facing summary completion.go surfaces as
+// a CompletionItem's Detail field.
+func (a Attr) Detail() string {
+	switch a.Kind {
+	case KindSet:
+		return "set"
+	case KindPackage:
+		if a.Version != "" {
+			return a.Path + " " + a.Version
+		}
+		return a.Path
+	default:
+		return a.Path
+	}
+}
+
+// Index is a point-in-time snapshot of nixpkgs' attribute names.
+type Index struct {
+	// Packages maps a package's full attribute path (e.g. "hello" or
+	// "python3Packages.requests") to its metadata.
+	Packages map[string]Attr
+	// LibAttrs maps lib's nested attribute paths, relative to lib itself
+	// (e.g. "strings", "strings.hasPrefix"), to their metadata, down to
+	// queryLibAttrs' bounded depth. Anything deeper isn't indexed and falls
+	// back to scope-only completion.
+	LibAttrs map[string]Attr
+}
+
+// ChildrenOf returns the attributes one level below prefixPath, e.g.
+// ChildrenOf("") for the top-level pkgs.* set (plus a synthetic "lib"
+// entry), ChildrenOf("python3Packages") for that set's members, or
+// ChildrenOf("lib.strings") for lib's own nested attrsets.
+func (idx *Index) ChildrenOf(prefixPath string) []Attr {
+	if prefixPath == "" {
+		return idx.topLevel()
+	}
+	if prefixPath == "lib" || strings.HasPrefix(prefixPath, "lib.") {
+		local := strings.TrimPrefix(strings.TrimPrefix(prefixPath, "lib"), ".")
+		children := childrenOfPrefix(idx.LibAttrs, local)
+		out := make([]Attr, len(children))
+		for i, c := range children {
+			out[i] = c
+			out[i].Path = "lib." + c.Path
+		}
+		return out
+	}
+	return childrenOfPrefix(idx.Packages, prefixPath)
+}
+
+func (idx *Index) topLevel() []Attr {
+	out := childrenOfPrefix(idx.Packages, "")
+	out = append(out, Attr{Path: "lib", Kind: KindSet})
+	return out
+}
+
+// childrenOfPrefix returns the attrs one level below prefix within attrs,
+// whose keys are dotted paths in the same namespace as prefix, synthesizing
+// an intermediate KindSet entry for any level attrs itself has no metadata
+// for (e.g. "python3Packages" when only "python3Packages.requests" is
+// indexed). prefix == "" returns the top-level names in attrs' namespace.
+func childrenOfPrefix(attrs map[string]Attr, prefix string) []Attr {
+	full := prefix
+	if full != "" {
+		full += "."
+	}
+	seen := make(map[string]bool)
+	var out []Attr
+	for key, attr := range attrs {
+		if !strings.HasPrefix(key, full) {
+			continue
+		}
+		rest := key[len(full):]
+		if rest == "" {
+			continue
+		}
+		name, isLeaf := rest, true
+		if i := strings.IndexByte(rest, '.'); i >= 0 {
+			name, isLeaf = rest[:i], false
+		}
+		childPath := full + name
+		if seen[childPath] {
+			continue
+		}
+		seen[childPath] = true
+		if isLeaf {
+			out = append(out, attr)
+		} else {
+			out = append(out, Attr{Path: childPath, Kind: KindSet})
+		}
+	}
+	return out
+}
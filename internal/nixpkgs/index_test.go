@@ -0,0 +1,97 @@
+package nixpkgs
+
+import "testing"
+
+func testIndex() *Index {
+	return &Index{
+		Packages: map[string]Attr{
+			"hello":                     {Path: "hello", Kind: KindPackage, Version: "2.12.1", Description: "A friendly program"},
+			"python3Packages.requests":  {Path: "python3Packages.requests", Kind: KindPackage, Version: "2.31.0"},
+			"python3Packages.flask":     {Path: "python3Packages.flask", Kind: KindPackage, Version: "3.0.0"},
+			"python3Packages.sub.inner": {Path: "python3Packages.sub.inner", Kind: KindPackage},
+		},
+		LibAttrs: map[string]Attr{
+			"strings":           {Path: "strings", Kind: KindSet},
+			"strings.hasPrefix": {Path: "strings.hasPrefix", Kind: KindValue},
+			"strings.hasSuffix": {Path: "strings.hasSuffix", Kind: KindValue},
+			"attrsets":          {Path: "attrsets", Kind: KindSet},
+			"attrsets.mapAttrs": {Path: "attrsets.mapAttrs", Kind: KindValue},
+		},
+	}
+}
+
+func TestTopLevelIncludesPackagesSetsAndLib(t *testing.T) {
+	idx := testIndex()
+	names := make(map[string]Kind)
+	for _, a := range idx.ChildrenOf("") {
+		names[a.Name()] = a.Kind
+	}
+	if names["hello"] != KindPackage {
+		t.Fatalf("hello should be a package, got %v", names["hello"])
+	}
+	if names["python3Packages"] != KindSet {
+		t.Fatalf("python3Packages should be a set, got %v", names["python3Packages"])
+	}
+	if names["lib"] != KindSet {
+		t.Fatalf("lib should be a synthetic set entry, got %v", names["lib"])
+	}
+}
+
+func TestChildrenOfSet(t *testing.T) {
+	idx := testIndex()
+	attrs := idx.ChildrenOf("python3Packages")
+	names := make(map[string]Kind)
+	for _, a := range attrs {
+		names[a.Name()] = a.Kind
+	}
+	if names["requests"] != KindPackage {
+		t.Fatalf("requests should be a package, got %v", names["requests"])
+	}
+	if names["sub"] != KindSet {
+		t.Fatalf("sub should be a set (nested package below it), got %v", names["sub"])
+	}
+}
+
+func TestChildrenOfLib(t *testing.T) {
+	idx := testIndex()
+	names := make(map[string]Kind)
+	for _, a := range idx.ChildrenOf("lib") {
+		names[a.Name()] = a.Kind
+	}
+	if names["strings"] != KindSet {
+		t.Fatalf("lib.strings should be a set, got %v", names["strings"])
+	}
+	if names["attrsets"] != KindSet {
+		t.Fatalf("lib.attrsets should be a set, got %v", names["attrsets"])
+	}
+}
+
+func TestChildrenOfNestedLib(t *testing.T) {
+	idx := testIndex()
+	names := make(map[string]Kind)
+	for _, a := range idx.ChildrenOf("lib.strings") {
+		names[a.Name()] = a.Kind
+	}
+	if names["hasPrefix"] != KindValue {
+		t.Fatalf("lib.strings.hasPrefix should be KindValue, got %v", names["hasPrefix"])
+	}
+	if names["hasSuffix"] != KindValue {
+		t.Fatalf("lib.strings.hasSuffix should be KindValue, got %v", names["hasSuffix"])
+	}
+	for _, a := range idx.ChildrenOf("lib.strings") {
+		if a.Path != "lib.strings."+a.Name() {
+			t.Fatalf("path = %q, want lib.strings.%s prefix", a.Path, a.Name())
+		}
+	}
+}
+
+func TestAttrDetail(t *testing.T) {
+	pkg := Attr{Path: "hello", Kind: KindPackage, Version: "2.12.1"}
+	if got := pkg.Detail(); got != "hello 2.12.1" {
+		t.Fatalf("Detail() = %q", got)
+	}
+	set := Attr{Path: "python3Packages", Kind: KindSet}
+	if got := set.Detail(); got != "set" {
+		t.Fatalf("Detail() = %q, want \"set\"", got)
+	}
+}
@@ -0,0 +1,132 @@
+package nixpkgs
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+)
+
+// Meta is the subset of a package's `meta` attrset that Hover surfaces.
+// nixpkgs lets homepage/license be either a single value or a list of them;
+// metaExpr reduces each down to one of these hover-friendly shapes.
+type Meta struct {
+	Description string   `json:"description"`
+	Homepage    string   `json:"homepage"`
+	License     string   `json:"license"`
+	Platforms   []string `json:"platforms"`
+}
+
+// QueryMeta evaluates pkgs.<path...>.meta via `nix-instantiate --eval
+// --json`. Unlike Build, which indexes every package's name and description
+// up front, this evaluates one attribute on demand — walking every
+// package's meta eagerly would mean evaluating all of nixpkgs.
+func QueryMeta(cfg Config, path []string) (*Meta, error) {
+	out, err := run(cfg.nixInstantiate(), "--eval", "--json", "-E", metaExpr(path))
+	if err != nil {
+		return nil, err
+	}
+	var m Meta
+	if err := json.Unmarshal(out, &m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// metaExpr builds a `nix-instantiate --eval` expression that walks pkgs
+// through path via builtins.foldl' (rather than string-splicing an
+// attribute-select chain, which an attribute name like "nodejs-18_x" can't
+// spell as an identifier) and reduces its meta down to hover-friendly
+// fields.
+func metaExpr(path []string) string {
+	var segs strings.Builder
+	for _, seg := range path {
+		segs.WriteString(quoteNixString(seg))
+		segs.WriteByte(' ')
+	}
+	return `let pkgs = import <nixpkgs> {}; ` +
+		`v = builtins.foldl' (acc: n: acc.${n}) pkgs [ ` + segs.String() + `]; ` +
+		`m = if builtins.hasAttr "meta" v then v.meta else {}; ` +
+		`toStr = x: if builtins.isAttrs x then (x.spdxId or x.fullName or "unknown") else toString x; ` +
+		`one = x: if builtins.isList x then (if x == [ ] then "" else toStr (builtins.head x)) else toStr x; ` +
+		`in { ` +
+		`description = m.description or ""; ` +
+		`homepage = one (m.homepage or ""); ` +
+		`license = if builtins.isList (m.license or "") ` +
+		`then builtins.concatStringsSep ", " (map toStr m.license) ` +
+		`else toStr (m.license or ""); ` +
+		`platforms = if builtins.isList (m.platforms or [ ]) then m.platforms else [ ]; ` +
+		`}`
+}
+
+func quoteNixString(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return `"` + s + `"`
+}
+
+// MetaCache serves Hover's pkgs.*.meta lookups, keeping each attribute
+// path's result in memory and, like LoadOrBuild's Index cache, on disk keyed
+// by nixpkgs's store path so a channel or flake update invalidates it.
+type MetaCache struct {
+	cfg Config
+
+	mu    sync.Mutex
+	cache map[string]*Meta
+}
+
+// NewMetaCache creates a MetaCache that shells out using cfg.
+func NewMetaCache(cfg Config) *MetaCache {
+	return &MetaCache{cfg: cfg, cache: make(map[string]*Meta)}
+}
+
+// NewStaticMetaCache wraps a fixed set of attribute-path-to-Meta entries,
+// with no shelling out at all. Useful for tests and for hover.Provider
+// callers that only have a previously saved cache file to go on.
+func NewStaticMetaCache(entries map[string]*Meta) *MetaCache {
+	cache := make(map[string]*Meta, len(entries))
+	for k, v := range entries {
+		cache[k] = v
+	}
+	return &MetaCache{cache: cache}
+}
+
+// Meta returns path's cached meta, evaluating and caching it (in memory and
+// on disk) on a miss.
+func (c *MetaCache) Meta(path []string) (*Meta, error) {
+	key := strings.Join(path, ".")
+
+	c.mu.Lock()
+	m, ok := c.cache[key]
+	c.mu.Unlock()
+	if ok {
+		return m, nil
+	}
+
+	storePath, storeErr := StorePath(c.cfg)
+	if storeErr == nil {
+		if file, err := metaCacheFile(storePath, key); err == nil {
+			if m, err := loadMetaCache(file); err == nil {
+				c.remember(key, m)
+				return m, nil
+			}
+		}
+	}
+
+	m, err := QueryMeta(c.cfg, path)
+	if err != nil {
+		return nil, err
+	}
+	c.remember(key, m)
+	if storeErr == nil {
+		if file, err := metaCacheFile(storePath, key); err == nil {
+			_ = saveMetaCache(file, m)
+		}
+	}
+	return m, nil
+}
+
+func (c *MetaCache) remember(key string, m *Meta) {
+	c.mu.Lock()
+	c.cache[key] = m
+	c.mu.Unlock()
+}
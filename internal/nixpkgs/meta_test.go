@@ -0,0 +1,98 @@
+package nixpkgs
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeBinCounting is fakeBin plus a sentinel file the script appends a line
+// to on every invocation, so a test can assert a cache actually avoided a
+// second call — counting in the Go process wouldn't see calls made by the
+// separate fake-bin process. It only succeeds (and echoes stdout) for a
+// `--json` invocation like QueryMeta's, failing StorePath's plain `--eval`
+// call so MetaCache never touches the real on-disk cache directory during
+// tests.
+func fakeBinCounting(t *testing.T, stdout string) (bin string, calls func() int) {
+	t.Helper()
+	dir := t.TempDir()
+	countFile := filepath.Join(dir, "calls")
+	path := filepath.Join(dir, "fake-bin")
+	script := "#!/bin/sh\n" +
+		"echo x >> '" + countFile + "'\n" +
+		"case \"$*\" in\n" +
+		"  *--json*) cat <<'EOF'\n" + stdout + "\nEOF\n" +
+		"  ;;\n" +
+		"  *) exit 1 ;;\n" +
+		"esac\n"
+	if err := os.WriteFile(path, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return path, func() int {
+		data, err := os.ReadFile(countFile)
+		if err != nil {
+			return 0
+		}
+		return len(strings.Split(strings.TrimRight(string(data), "\n"), "\n"))
+	}
+}
+
+func TestQueryMetaParsesFields(t *testing.T) {
+	bin := fakeBin(t, `{"description":"A friendly program","homepage":"https://example.org","license":"mit","platforms":["x86_64-linux"]}`)
+
+	m, err := QueryMeta(Config{NixInstantiate: bin}, []string{"hello"})
+	if err != nil {
+		t.Fatalf("QueryMeta: %v", err)
+	}
+	if m.Description != "A friendly program" {
+		t.Fatalf("description = %q", m.Description)
+	}
+	if m.Homepage != "https://example.org" {
+		t.Fatalf("homepage = %q", m.Homepage)
+	}
+	if len(m.Platforms) != 1 || m.Platforms[0] != "x86_64-linux" {
+		t.Fatalf("platforms = %v", m.Platforms)
+	}
+}
+
+func TestMetaExprQuotesEachSegment(t *testing.T) {
+	expr := metaExpr([]string{"python3Packages", `weird"name`})
+	if want := `"python3Packages"`; !contains(expr, want) {
+		t.Fatalf("expr %q missing %q", expr, want)
+	}
+	if want := `"weird\"name"`; !contains(expr, want) {
+		t.Fatalf("expr %q missing escaped %q", expr, want)
+	}
+}
+
+func TestMetaCacheServesSecondLookupFromMemory(t *testing.T) {
+	bin, calls := fakeBinCounting(t, `{"description":"cached"}`)
+
+	c := NewMetaCache(Config{NixInstantiate: bin})
+	for i := 0; i < 2; i++ {
+		m, err := c.Meta([]string{"hello"})
+		if err != nil {
+			t.Fatalf("Meta: %v", err)
+		}
+		if m.Description != "cached" {
+			t.Fatalf("description = %q", m.Description)
+		}
+	}
+	// StorePath and QueryMeta both shell out to the same fake binary; the
+	// first Meta() call invokes it twice (a failing store-path lookup, then
+	// a successful eval), and the second should invoke it zero more times
+	// by serving from the in-memory map.
+	if got := calls(); got != 2 {
+		t.Fatalf("nix-instantiate invoked %d times, want 2 (store path + eval, once)", got)
+	}
+}
+
+func contains(s, sub string) bool {
+	for i := 0; i+len(sub) <= len(s); i++ {
+		if s[i:i+len(sub)] == sub {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,60 @@
+package nixpkgs
+
+import "sync"
+
+// Provider holds the server's current view of the nixpkgs index, built
+// once in the background at startup so a slow first `nix-env -qaP` doesn't
+// block the server from answering other requests. Completion treats a nil
+// Index() as "not ready yet" and falls back to scope-only suggestions.
+type Provider struct {
+	mu  sync.Mutex
+	idx *Index
+}
+
+// NewProvider starts building the index in the background and returns
+// immediately; Index() returns nil until that build completes.
+func NewProvider(cfg Config) *Provider {
+	p := &Provider{}
+	go p.refresh(cfg)
+	return p
+}
+
+// NewStaticProvider wraps an already-built Index, with no background
+// build. Useful for tests and for an offline mode seeded from a
+// previously saved cache file.
+func NewStaticProvider(idx *Index) *Provider {
+	return &Provider{idx: idx}
+}
+
+func (p *Provider) refresh(cfg Config) {
+	idx, err := LoadOrBuild(cfg)
+	if err != nil {
+		return // no nix toolchain available; completion just won't have pkgs/lib entries
+	}
+	p.mu.Lock()
+	p.idx = idx
+	p.mu.Unlock()
+}
+
+// Index returns the current Index, or nil if the background build hasn't
+// finished (or failed) yet.
+func (p *Provider) Index() *Index {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.idx
+}
+
+// Refresh rebuilds the index from scratch and swaps it in once done,
+// blocking the caller. Use this when the nixpkgs input is known to have
+// changed (e.g. a flake.lock update) rather than waiting for the process to
+// restart.
+func (p *Provider) Refresh(cfg Config) error {
+	idx, err := Build(cfg)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	p.idx = idx
+	p.mu.Unlock()
+	return nil
+}
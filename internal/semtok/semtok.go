@@ -0,0 +1,298 @@
+// Package semtok computes LSP semantic tokens for a parsed Nix document. It
+// re-walks the raw lexical token stream for keywords, literals, and
+// operators, overlays a pass over the AST to tell an attribute name or
+// function parameter apart from an ordinary variable reference, and finds
+// comments (which the lexer discards as trivia) by scanning the gaps
+// between lexical tokens. The result is encoded in the LSP's relative
+// delta-encoded []uint32 wire format.
+package semtok
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/legonois/nixos-lsp/internal/analysis"
+	"github.com/legonois/nixos-lsp/internal/nix"
+
+	"go.lsp.dev/protocol"
+)
+
+// Types is the token type legend this package encodes against; a token's
+// type in Full/Range's output is an index into this slice.
+var Types = []protocol.SemanticTokenTypes{
+	protocol.SemanticTokenKeyword,
+	protocol.SemanticTokenComment,
+	protocol.SemanticTokenString,
+	protocol.SemanticTokenNumber,
+	protocol.SemanticTokenVariable,
+	protocol.SemanticTokenProperty,
+	protocol.SemanticTokenParameter,
+	protocol.SemanticTokenOperator,
+}
+
+// Modifiers is empty: this package doesn't distinguish declarations from
+// uses, so every token is reported with a zero modifier bitset.
+var Modifiers = []protocol.SemanticTokenModifiers{}
+
+const (
+	typeKeyword uint32 = iota
+	typeComment
+	typeString
+	typeNumber
+	typeVariable
+	typeProperty
+	typeParameter
+	typeOperator
+)
+
+// tok is a single-or-multi-line candidate token before it's split onto
+// individual source lines and delta-encoded.
+type tok struct {
+	start nix.Position
+	end   nix.Position
+	typ   uint32
+}
+
+// Full computes semantic tokens for doc's entire text.
+func Full(doc *analysis.Document) []uint32 {
+	return encode(doc.Text, collect(doc))
+}
+
+// Range computes semantic tokens for the portion of doc's text covered by
+// span, for clients that request tokens incrementally as the viewport
+// scrolls.
+func Range(doc *analysis.Document, span nix.Span) []uint32 {
+	var out []tok
+	for _, t := range collect(doc) {
+		if t.start.Offset >= span.Start.Offset && t.start.Offset < span.End.Offset {
+			out = append(out, t)
+		}
+	}
+	return encode(doc.Text, out)
+}
+
+// collect gathers every token candidate for doc, splits any that span
+// multiple lines (block comments, indented strings) into one token per
+// line, and returns them unsorted.
+func collect(doc *analysis.Document) []tok {
+	text := doc.Text
+	lexToks := nix.Tokenize(text)
+	overrides := identOverrides(doc.File.Root)
+
+	var raw []tok
+	for _, t := range lexToks {
+		typ, ok := lexicalType(t.Kind)
+		if !ok {
+			continue
+		}
+		if t.Kind == nix.TokenIdent {
+			if o, ok2 := overrides[t.Start.Offset]; ok2 {
+				typ = o
+			}
+		}
+		raw = append(raw, tok{start: t.Start, end: t.End, typ: typ})
+	}
+	raw = append(raw, commentTokens(text, lexToks)...)
+
+	out := make([]tok, 0, len(raw))
+	for _, t := range raw {
+		out = append(out, splitLines(text, t)...)
+	}
+	return out
+}
+
+// lexicalType maps a lexical TokenKind to its semantic token type. Pure
+// punctuation (braces, parens, dot, ...) isn't classified at all, so
+// clients fall back to their default coloring for it.
+func lexicalType(k nix.TokenKind) (uint32, bool) {
+	switch k {
+	case nix.TokenIf, nix.TokenThen, nix.TokenElse, nix.TokenLet, nix.TokenIn,
+		nix.TokenWith, nix.TokenRec, nix.TokenInherit, nix.TokenAssert, nix.TokenOr_:
+		return typeKeyword, true
+	case nix.TokenStringStart, nix.TokenStringPart, nix.TokenStringEnd,
+		nix.TokenPath, nix.TokenSPath, nix.TokenURI:
+		return typeString, true
+	case nix.TokenInt, nix.TokenFloat:
+		return typeNumber, true
+	case nix.TokenIdent:
+		return typeVariable, true
+	case nix.TokenAssign, nix.TokenPlus, nix.TokenMinus, nix.TokenStar, nix.TokenSlash,
+		nix.TokenConcat, nix.TokenUpdate, nix.TokenEq, nix.TokenNeq, nix.TokenLt, nix.TokenLte,
+		nix.TokenGt, nix.TokenGte, nix.TokenAnd, nix.TokenOr, nix.TokenImplies, nix.TokenNot,
+		nix.TokenQuestion:
+		return typeOperator, true
+	default:
+		return 0, false
+	}
+}
+
+// identOverrides walks root and returns, for every identifier-ish span
+// that is an attribute name or a function parameter rather than a plain
+// variable reference, the semantic type it should be reported as instead
+// of the default typeVariable. The map is keyed by the span's start byte
+// offset, which is unique per token.
+func identOverrides(root nix.Expr) map[int]uint32 {
+	overrides := make(map[int]uint32)
+	if root == nil {
+		return overrides
+	}
+
+	markPath := func(path []nix.AttrPathSegment) {
+		for _, seg := range path {
+			if seg.Name != "" {
+				overrides[seg.Span.Start.Offset] = typeProperty
+			}
+		}
+	}
+
+	nix.Walk(root, func(n nix.Node) {
+		switch v := n.(type) {
+		case *nix.AttrBinding:
+			markPath(v.Path)
+		case *nix.InheritBinding:
+			markPath(v.Names)
+		case *nix.Select:
+			markPath(v.Path)
+		case *nix.HasAttr:
+			markPath(v.Path)
+		case *nix.IdentParam:
+			overrides[v.Span().Start.Offset] = typeParameter
+		case *nix.PatternParam:
+			for _, f := range v.Fields {
+				overrides[f.Span.Start.Offset] = typeParameter
+			}
+		}
+	})
+	return overrides
+}
+
+// commentTokens finds `#...` and `/* ... */` comments by scanning the gaps
+// between consecutive lexical tokens (the lexer's skipTrivia discards
+// comments entirely, so they never appear in lexToks itself). Scanning only
+// the gaps, rather than the raw text, means a `#` or `/*` inside a string
+// literal is never mistaken for a comment: string contents are covered by
+// their own TokenStringPart span, not a gap.
+func commentTokens(text string, lexToks []nix.Token) []tok {
+	var out []tok
+	prev := nix.Position{}
+	for _, t := range lexToks {
+		if t.Start.Offset > prev.Offset {
+			out = append(out, scanGapComments(text[prev.Offset:t.Start.Offset], prev)...)
+		}
+		if t.End.Offset > prev.Offset {
+			prev = t.End
+		}
+	}
+	return out
+}
+
+// scanGapComments finds comments within gap, a slice of text starting at
+// position from.
+func scanGapComments(gap string, from nix.Position) []tok {
+	var out []tok
+	i, line, col, offset := 0, from.Line, from.Column, from.Offset
+	advance := func(n int) {
+		for k := 0; k < n; k++ {
+			if gap[i+k] == '\n' {
+				line++
+				col = 0
+			} else {
+				col++
+			}
+		}
+		i += n
+		offset += n
+	}
+	pos := func() nix.Position { return nix.Position{Line: line, Column: col, Offset: offset} }
+
+	for i < len(gap) {
+		switch {
+		case gap[i] == '#':
+			start := pos()
+			if nl := strings.IndexByte(gap[i:], '\n'); nl >= 0 {
+				advance(nl)
+			} else {
+				advance(len(gap) - i)
+			}
+			out = append(out, tok{start: start, end: pos(), typ: typeComment})
+		case strings.HasPrefix(gap[i:], "/*"):
+			start := pos()
+			if end := strings.Index(gap[i:], "*/"); end >= 0 {
+				advance(end + 2)
+			} else {
+				advance(len(gap) - i)
+			}
+			out = append(out, tok{start: start, end: pos(), typ: typeComment})
+		default:
+			advance(1)
+		}
+	}
+	return out
+}
+
+// splitLines breaks t into one token per source line it covers, since the
+// LSP semantic tokens format requires every token to lie on a single line.
+func splitLines(text string, t tok) []tok {
+	if t.start.Line == t.end.Line {
+		return []tok{t}
+	}
+	var out []tok
+	line, col, offset := t.start.Line, t.start.Column, t.start.Offset
+	for offset < t.end.Offset {
+		rest := text[offset:t.end.Offset]
+		nl := strings.IndexByte(rest, '\n')
+		lineEnd := t.end.Offset
+		if nl >= 0 {
+			lineEnd = offset + nl
+		}
+		out = append(out, tok{
+			start: nix.Position{Line: line, Column: col, Offset: offset},
+			end:   nix.Position{Line: line, Column: col + (lineEnd - offset), Offset: lineEnd},
+			typ:   t.typ,
+		})
+		if nl < 0 {
+			break
+		}
+		offset, line, col = lineEnd+1, line+1, 0
+	}
+	return out
+}
+
+// encode sorts toks into source order and delta-encodes them per the LSP
+// SemanticTokens.Data format: each token contributes five uint32s
+// (deltaLine, deltaStartChar, length, tokenType, tokenModifiers). Lengths
+// and character deltas are counted in UTF-16 code units, per the LSP spec,
+// not the byte columns nix.Position tracks.
+func encode(text string, toks []tok) []uint32 {
+	sort.Slice(toks, func(i, j int) bool {
+		if toks[i].start.Line != toks[j].start.Line {
+			return toks[i].start.Line < toks[j].start.Line
+		}
+		return toks[i].start.Column < toks[j].start.Column
+	})
+
+	data := make([]uint32, 0, len(toks)*5)
+	prevLine, prevCol := 0, 0
+	for _, t := range toks {
+		length := analysis.UTF16Units(text[t.start.Offset:t.end.Offset])
+		if length <= 0 {
+			continue
+		}
+		startCol := utf16Column(text, t.start)
+		deltaLine := t.start.Line - prevLine
+		deltaCol := startCol
+		if deltaLine == 0 {
+			deltaCol = startCol - prevCol
+		}
+		data = append(data, uint32(deltaLine), uint32(deltaCol), uint32(length), t.typ, 0)
+		prevLine, prevCol = t.start.Line, startCol
+	}
+	return data
+}
+
+// utf16Column counts pos's column, from the start of its line, in UTF-16
+// code units rather than the bytes nix.Position.Column tracks.
+func utf16Column(text string, pos nix.Position) int {
+	lineStart := pos.Offset - pos.Column
+	return analysis.UTF16Units(text[lineStart:pos.Offset])
+}
@@ -0,0 +1,133 @@
+package semtok
+
+import (
+	"testing"
+
+	"go.lsp.dev/protocol"
+
+	"github.com/legonois/nixos-lsp/internal/analysis"
+)
+
+type decoded struct {
+	line, char, length int
+	typ                uint32
+}
+
+func decode(data []uint32) []decoded {
+	var out []decoded
+	line, char := 0, 0
+	for i := 0; i < len(data); i += 5 {
+		dl, dc, length, typ := data[i], data[i+1], data[i+2], data[i+3]
+		if dl == 0 {
+			char += int(dc)
+		} else {
+			line += int(dl)
+			char = int(dc)
+		}
+		out = append(out, decoded{line, char, int(length), typ})
+	}
+	return out
+}
+
+func parse(t *testing.T, src string) *analysis.Document {
+	t.Helper()
+	sess := analysis.NewSession()
+	u := protocol.URI("file:///t.nix")
+	snap := sess.Open(u, src, 1)
+	doc, _ := snap.Get(u)
+	return doc
+}
+
+func findText(t *testing.T, src string, toks []decoded, text string, typ uint32) decoded {
+	t.Helper()
+	for _, d := range toks {
+		lineStart := 0
+		for i := 0; i < d.line; i++ {
+			lineStart += len(splitLine(src, i)) + 1
+		}
+		if src[lineStart+d.char:lineStart+d.char+d.length] == text && d.typ == typ {
+			return d
+		}
+	}
+	t.Fatalf("no %d-token %q found in %#v", typ, text, toks)
+	return decoded{}
+}
+
+func splitLine(src string, n int) string {
+	lines := []string{}
+	start := 0
+	for i := 0; i < len(src); i++ {
+		if src[i] == '\n' {
+			lines = append(lines, src[start:i])
+			start = i + 1
+		}
+	}
+	lines = append(lines, src[start:])
+	return lines[n]
+}
+
+func TestFullKeywordStringNumber(t *testing.T) {
+	src := `let x = "hi"; in 1`
+	doc := parse(t, src)
+	toks := decode(Full(doc))
+
+	findText(t, src, toks, "let", typeKeyword)
+	findText(t, src, toks, "in", typeKeyword)
+	findText(t, src, toks, `"`, typeString)
+	findText(t, src, toks, "1", typeNumber)
+}
+
+func TestFullAttributeNameVsValue(t *testing.T) {
+	src := "{ foo = bar; }"
+	doc := parse(t, src)
+	toks := decode(Full(doc))
+
+	findText(t, src, toks, "foo", typeProperty)
+	findText(t, src, toks, "bar", typeVariable)
+}
+
+func TestFullFunctionParameters(t *testing.T) {
+	src := "{ a, b ? 1 }: a"
+	doc := parse(t, src)
+	toks := decode(Full(doc))
+
+	findText(t, src, toks, "a", typeParameter)
+	findText(t, src, toks, "b", typeParameter)
+}
+
+func TestFullComment(t *testing.T) {
+	src := "# greeting\nlet x = 1; in x"
+	doc := parse(t, src)
+	toks := decode(Full(doc))
+
+	findText(t, src, toks, "# greeting", typeComment)
+}
+
+func TestFullCommentUsesUTF16Length(t *testing.T) {
+	// "😀" is one astral-plane rune: 4 UTF-8 bytes, but 2 UTF-16 code units
+	// (a surrogate pair) — the unit LSP token lengths/columns count in. The
+	// comment "# 😀 comment" is 14 bytes but 12 UTF-16 units.
+	src := "# 😀 comment\nlet x = 1; in x"
+	doc := parse(t, src)
+	data := Full(doc)
+
+	// deltaLine, deltaStartChar, length, tokenType, tokenModifiers for the
+	// first (and only) token on line 0.
+	if len(data) < 5 {
+		t.Fatalf("got %d uint32s, want at least 5", len(data))
+	}
+	if length := data[2]; length != 12 {
+		t.Fatalf("comment token length = %d, want 12 (UTF-16 units, not 14 UTF-8 bytes)", length)
+	}
+}
+
+func TestRangeFiltersToSpan(t *testing.T) {
+	src := "let x = 1; in x"
+	doc := parse(t, src)
+
+	full := decode(Full(doc))
+	ranged := decode(Range(doc, doc.File.Root.Span()))
+	if len(ranged) != len(full) {
+		t.Fatalf("range over the whole file got %d tokens, want %d", len(ranged), len(full))
+	}
+}
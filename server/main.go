@@ -5,24 +5,79 @@ import (
 	"encoding/json"
 	"log"
 	"os"
+	"unicode/utf16"
+	"unicode/utf8"
 
 	"go.lsp.dev/jsonrpc2"
 	"go.lsp.dev/protocol"
+
+	"github.com/legonois/nixos-lsp/internal/analysis"
+	"github.com/legonois/nixos-lsp/internal/completion"
+	"github.com/legonois/nixos-lsp/internal/diagnostics"
+	"github.com/legonois/nixos-lsp/internal/format"
+	"github.com/legonois/nixos-lsp/internal/hover"
+	"github.com/legonois/nixos-lsp/internal/nix"
+	"github.com/legonois/nixos-lsp/internal/nixpkgs"
+	"github.com/legonois/nixos-lsp/internal/semtok"
 )
 
+// semanticTokensOptions fills in the legend/full/range shape the LSP spec
+// defines for the semanticTokensProvider capability. go.lsp.dev/protocol's
+// SemanticTokensOptions is stubbed down to WorkDoneProgressOptions only, so
+// ServerCapabilities.SemanticTokensProvider is declared as interface{}
+// specifically so a fuller local type like this one can be used instead.
+type semanticTokensOptions struct {
+	protocol.WorkDoneProgressOptions
+	Legend protocol.SemanticTokensLegend `json:"legend"`
+	Range  bool                          `json:"range,omitempty"`
+	Full   bool                          `json:"full,omitempty"`
+}
+
 func main() {
 	ctx := context.Background()
 
 	stream := jsonrpc2.NewStream(os.Stdin)
 	conn := jsonrpc2.NewConn(stream)
-	handler := &server{conn: conn, files: make(map[protocol.URI]string)}
+	handler := &server{conn: conn, session: analysis.NewSession(), format: format.DefaultConfig()}
+	handler.diagnostics = diagnostics.NewWorker(diagnostics.DefaultConfig(), connPublisher{conn})
+	handler.completion = completion.New(nixpkgs.NewProvider(nixpkgs.Config{}))
+	handler.hover = hover.New(nixpkgs.NewMetaCache(nixpkgs.Config{}))
 	conn.Go(ctx, handler.Handler)
 	<-conn.Done()
 }
 
 type server struct {
-	conn  jsonrpc2.Conn
-	files map[protocol.URI]string // map of file URIs to their contents
+	conn        jsonrpc2.Conn
+	session     *analysis.Session    // current and historical workspace snapshots
+	diagnostics *diagnostics.Worker  // off-thread nix-instantiate checks
+	completion  *completion.Provider // scope- and nixpkgs-aware completion
+	hover       *hover.Provider      // scope- and nixpkgs-meta-aware hover
+	format      format.Config        // formatter choice, set from InitializationOptions
+}
+
+// initializationOptions is the subset of the client's InitializationOptions
+// this server understands, parsed directly from the initialize request's
+// raw params rather than through protocol.InitializeParams's untyped
+// InitializationOptions interface{} field.
+type initializationOptions struct {
+	InitializationOptions struct {
+		Formatter struct {
+			Command string   `json:"command"`
+			Args    []string `json:"args"`
+		} `json:"formatter"`
+	} `json:"initializationOptions"`
+}
+
+// connPublisher adapts a jsonrpc2.Conn to diagnostics.Publisher.
+type connPublisher struct {
+	conn jsonrpc2.Conn
+}
+
+func (p connPublisher) PublishDiagnostics(uri protocol.URI, diags []protocol.Diagnostic) {
+	p.conn.Notify(context.Background(), protocol.MethodTextDocumentPublishDiagnostics, &protocol.PublishDiagnosticsParams{
+		URI:         protocol.DocumentURI(uri),
+		Diagnostics: diags,
+	})
 }
 
 func (s *server) Handler(ctx context.Context, reply jsonrpc2.Replier, req jsonrpc2.Request) error {
@@ -35,13 +90,35 @@ func (s *server) Handler(ctx context.Context, reply jsonrpc2.Replier, req jsonrp
 		if err := json.Unmarshal(req.Params(), &params); err != nil {
 			return err
 		}
+
+		var opts initializationOptions
+		if err := json.Unmarshal(req.Params(), &opts); err == nil && opts.InitializationOptions.Formatter.Command != "" {
+			s.format.Formatter = opts.InitializationOptions.Formatter.Command
+			s.format.Args = opts.InitializationOptions.Formatter.Args
+		}
+
 		reply(ctx, protocol.InitializeResult{
 			Capabilities: protocol.ServerCapabilities{
-				TextDocumentSync: protocol.TextDocumentSyncKindIncremental,
-				SemanticTokensProvider: protocol.SemanticTokensOptions{
-					WorkDoneProgressOptions: protocol.WorkDoneProgressOptions{
-						WorkDoneProgress: true,
+				TextDocumentSync: protocol.TextDocumentSyncOptions{
+					OpenClose: true,
+					Change:    protocol.TextDocumentSyncKindIncremental,
+					Save:      &protocol.SaveOptions{IncludeText: false},
+				},
+				DefinitionProvider:              true,
+				ReferencesProvider:              true,
+				HoverProvider:                   true,
+				DocumentFormattingProvider:      true,
+				DocumentRangeFormattingProvider: true,
+				CompletionProvider: &protocol.CompletionOptions{
+					TriggerCharacters: []string{"."},
+				},
+				SemanticTokensProvider: semanticTokensOptions{
+					Legend: protocol.SemanticTokensLegend{
+						TokenTypes:     semtok.Types,
+						TokenModifiers: semtok.Modifiers,
 					},
+					Full:  true,
+					Range: true,
 				},
 			},
 		}, nil)
@@ -72,26 +149,38 @@ func (s *server) Handler(ctx context.Context, reply jsonrpc2.Replier, req jsonrp
 			return err
 		}
 
-		s.files[params.TextDocument.URI] = params.TextDocument.Text
+		s.session.Open(params.TextDocument.URI, params.TextDocument.Text, params.TextDocument.Version)
+		s.diagnostics.OnChange(params.TextDocument.URI, params.TextDocument.URI.Filename())
 		log.Printf("Opened %s", params.TextDocument.URI)
 		return nil
 
 	// textDocument/didChange
 	// handle change in document
 	case protocol.MethodTextDocumentDidChange:
-		var params protocol.DidChangeTextDocumentParams
+		var params rawDidChangeParams
 		if err := json.Unmarshal(req.Params(), &params); err != nil {
 			return err
 		}
 
-		// store full document text (since TextDocumentSyncKindFull)
-		if len(params.ContentChanges) > 0 {
-			s.files[params.TextDocument.URI] = params.ContentChanges[0].Text
-		}
+		text := s.applyChanges(params.TextDocument.URI, params.ContentChanges)
+		s.session.Open(params.TextDocument.URI, text, params.TextDocument.Version)
+		s.diagnostics.OnChange(params.TextDocument.URI, params.TextDocument.URI.Filename())
 		log.Printf("Changed %s (version %d)",
 			params.TextDocument.URI, params.TextDocument.Version)
 		return nil
 
+	// textDocument/didSave
+	// handle document save: run a stricter, immediate diagnostics pass
+	case protocol.MethodTextDocumentDidSave:
+		var params protocol.DidSaveTextDocumentParams
+		if err := json.Unmarshal(req.Params(), &params); err != nil {
+			return err
+		}
+
+		s.diagnostics.OnSave(params.TextDocument.URI, params.TextDocument.URI.Filename())
+		log.Printf("Saved %s", params.TextDocument.URI)
+		return nil
+
 	// textDocument/completion
 	// handle completion request
 	case protocol.MethodTextDocumentCompletion:
@@ -100,10 +189,14 @@ func (s *server) Handler(ctx context.Context, reply jsonrpc2.Replier, req jsonrp
 			return err
 		}
 
-		items := []protocol.CompletionItem{{
-			Label: "HelloWorld",
-			Kind:  protocol.CompletionItemKindText,
-		}}
+		snap := s.session.Snapshot()
+		doc, ok := snap.Get(params.TextDocument.URI)
+		if !ok {
+			reply(ctx, protocol.CompletionList{}, nil)
+			return nil
+		}
+		pos := analysis.PosFromLSP(params.Position, doc.Text)
+		items := s.completion.Complete(snap, doc, pos)
 
 		reply(ctx, protocol.CompletionList{
 			IsIncomplete: false,
@@ -119,16 +212,14 @@ func (s *server) Handler(ctx context.Context, reply jsonrpc2.Replier, req jsonrp
 			return err
 		}
 
-		reply(ctx, &protocol.Hover{
-			Contents: protocol.MarkupContent{
-				Kind:  protocol.Markdown,
-				Value: "**Hover** example",
-			},
-			Range: &protocol.Range{
-				Start: protocol.Position{Line: 0, Character: 0},
-				End:   protocol.Position{Line: 0, Character: 5},
-			},
-		}, nil)
+		snap := s.session.Snapshot()
+		doc, ok := snap.Get(params.TextDocument.URI)
+		if !ok {
+			reply(ctx, nil, nil)
+			return nil
+		}
+		pos := analysis.PosFromLSP(params.Position, doc.Text)
+		reply(ctx, s.hover.Hover(snap, doc, pos), nil)
 		return nil
 
 	// textDocument/definition
@@ -139,15 +230,116 @@ func (s *server) Handler(ctx context.Context, reply jsonrpc2.Replier, req jsonrp
 			return err
 		}
 
-		loc := protocol.Location{
-			URI: params.TextDocument.URI,
-			Range: protocol.Range{
-				Start: protocol.Position{Line: 0, Character: 0},
-				End:   protocol.Position{Line: 0, Character: 5},
-			},
+		snap := s.session.Snapshot()
+		doc, ok := snap.Get(params.TextDocument.URI)
+		if !ok {
+			reply(ctx, []protocol.Location{}, nil)
+			return nil
+		}
+		pos := analysis.PosFromLSP(params.Position, doc.Text)
+		reply(ctx, snap.Definition(doc, pos), nil)
+		return nil
+
+	// textDocument/references
+	// handle find-references request
+	case protocol.MethodTextDocumentReferences:
+		var params protocol.ReferenceParams
+		if err := json.Unmarshal(req.Params(), &params); err != nil {
+			return err
+		}
+
+		snap := s.session.Snapshot()
+		doc, ok := snap.Get(params.TextDocument.URI)
+		if !ok {
+			reply(ctx, []protocol.Location{}, nil)
+			return nil
+		}
+		pos := analysis.PosFromLSP(params.Position, doc.Text)
+		reply(ctx, snap.References(doc, pos), nil)
+		return nil
+
+	// textDocument/semanticTokens/full
+	// handle full-document semantic tokens request
+	case protocol.MethodSemanticTokensFull:
+		var params protocol.SemanticTokensParams
+		if err := json.Unmarshal(req.Params(), &params); err != nil {
+			return err
 		}
 
-		reply(ctx, []protocol.Location{loc}, nil)
+		snap := s.session.Snapshot()
+		doc, ok := snap.Get(params.TextDocument.URI)
+		if !ok {
+			reply(ctx, &protocol.SemanticTokens{}, nil)
+			return nil
+		}
+		reply(ctx, &protocol.SemanticTokens{Data: semtok.Full(doc)}, nil)
+		return nil
+
+	// textDocument/semanticTokens/range
+	// handle semantic tokens request scoped to a visible range
+	case protocol.MethodSemanticTokensRange:
+		var params protocol.SemanticTokensRangeParams
+		if err := json.Unmarshal(req.Params(), &params); err != nil {
+			return err
+		}
+
+		snap := s.session.Snapshot()
+		doc, ok := snap.Get(params.TextDocument.URI)
+		if !ok {
+			reply(ctx, &protocol.SemanticTokens{}, nil)
+			return nil
+		}
+		span := nix.Span{
+			Start: analysis.PosFromLSP(params.Range.Start, doc.Text),
+			End:   analysis.PosFromLSP(params.Range.End, doc.Text),
+		}
+		reply(ctx, &protocol.SemanticTokens{Data: semtok.Range(doc, span)}, nil)
+		return nil
+
+	// textDocument/formatting
+	// handle whole-document formatting request
+	case protocol.MethodTextDocumentFormatting:
+		var params protocol.DocumentFormattingParams
+		if err := json.Unmarshal(req.Params(), &params); err != nil {
+			return err
+		}
+
+		snap := s.session.Snapshot()
+		doc, ok := snap.Get(params.TextDocument.URI)
+		if !ok {
+			reply(ctx, []protocol.TextEdit{}, nil)
+			return nil
+		}
+		edits, err := format.Format(s.format, doc.Text)
+		if err != nil {
+			log.Printf("format %s: %v", params.TextDocument.URI, err)
+			reply(ctx, []protocol.TextEdit{}, nil)
+			return nil
+		}
+		reply(ctx, edits, nil)
+		return nil
+
+	// textDocument/rangeFormatting
+	// handle range-scoped formatting request
+	case protocol.MethodTextDocumentRangeFormatting:
+		var params protocol.DocumentRangeFormattingParams
+		if err := json.Unmarshal(req.Params(), &params); err != nil {
+			return err
+		}
+
+		snap := s.session.Snapshot()
+		doc, ok := snap.Get(params.TextDocument.URI)
+		if !ok {
+			reply(ctx, []protocol.TextEdit{}, nil)
+			return nil
+		}
+		edits, err := format.FormatRange(s.format, doc.Text, params.Range)
+		if err != nil {
+			log.Printf("range format %s: %v", params.TextDocument.URI, err)
+			reply(ctx, []protocol.TextEdit{}, nil)
+			return nil
+		}
+		reply(ctx, edits, nil)
 		return nil
 
 	default:
@@ -156,3 +348,85 @@ func (s *server) Handler(ctx context.Context, reply jsonrpc2.Replier, req jsonrp
 		return jsonrpc2.MethodNotFoundHandler(ctx, reply, req)
 	}
 }
+
+// rawContentChange mirrors protocol.TextDocumentContentChangeEvent but keeps
+// Range as a pointer: go.lsp.dev/protocol declares Range as a plain struct,
+// which makes an omitted (full-replacement) range indistinguishable from an
+// explicit zero-length range at the start of the document.
+type rawContentChange struct {
+	Range       *protocol.Range `json:"range,omitempty"`
+	RangeLength uint32          `json:"rangeLength,omitempty"`
+	Text        string          `json:"text"`
+}
+
+type rawDidChangeParams struct {
+	TextDocument   protocol.VersionedTextDocumentIdentifier `json:"textDocument"`
+	ContentChanges []rawContentChange                       `json:"contentChanges"`
+}
+
+// applyChanges folds a didChange notification's content changes onto the
+// document's last known text, honoring TextDocumentSyncKindIncremental
+// (a change with no Range is a full-text replacement).
+func (s *server) applyChanges(uri protocol.URI, changes []rawContentChange) string {
+	text := ""
+	if doc, ok := s.session.Snapshot().Get(uri); ok {
+		text = doc.Text
+	}
+	for _, change := range changes {
+		if change.Range == nil {
+			text = change.Text
+			continue
+		}
+		text = applyRangeChange(text, *change.Range, change.Text)
+	}
+	return text
+}
+
+// applyRangeChange splices newText into text at the UTF-16 line/character
+// position described by r, per the LSP TextDocumentContentChangeEvent spec.
+func applyRangeChange(text string, r protocol.Range, newText string) string {
+	lines := splitLinesKeepEnds(text)
+	start := offsetOf(lines, r.Start)
+	end := offsetOf(lines, r.End)
+	if start > len(text) || end > len(text) || start > end {
+		return text // malformed range; drop the edit rather than corrupt the buffer
+	}
+	return text[:start] + newText + text[end:]
+}
+
+func splitLinesKeepEnds(text string) []string {
+	var lines []string
+	start := 0
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\n' {
+			lines = append(lines, text[start:i+1])
+			start = i + 1
+		}
+	}
+	lines = append(lines, text[start:])
+	return lines
+}
+
+func offsetOf(lines []string, pos protocol.Position) int {
+	offset := 0
+	for i := 0; i < int(pos.Line) && i < len(lines); i++ {
+		offset += len(lines[i])
+	}
+	if int(pos.Line) >= len(lines) {
+		return offset
+	}
+	return offset + utf16ColumnToByteOffset(lines[pos.Line], int(pos.Character))
+}
+
+// utf16ColumnToByteOffset converts a 0-based UTF-16 code unit count within
+// line into the matching byte offset, per the LSP spec's UTF-16 Position
+// encoding.
+func utf16ColumnToByteOffset(line string, utf16Col int) int {
+	offset, units := 0, 0
+	for units < utf16Col && offset < len(line) {
+		r, size := utf8.DecodeRuneInString(line[offset:])
+		offset += size
+		units += utf16.RuneLen(r)
+	}
+	return offset
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"testing"
+
+	"go.lsp.dev/protocol"
+)
+
+func TestApplyRangeChangeHandlesUTF16Columns(t *testing.T) {
+	// "😀" is one astral-plane rune: 4 UTF-8 bytes, but 2 UTF-16 code units
+	// (a surrogate pair) — the unit LSP ranges count columns in. Replacing
+	// the character right after it must land on a byte boundary, not split
+	// the rune in half.
+	text := "😀x = 1;"
+	r := protocol.Range{
+		Start: protocol.Position{Line: 0, Character: 2},
+		End:   protocol.Position{Line: 0, Character: 3},
+	}
+	got := applyRangeChange(text, r, "y")
+	want := "😀y = 1;"
+	if got != want {
+		t.Fatalf("applyRangeChange = %q, want %q", got, want)
+	}
+}